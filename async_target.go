@@ -0,0 +1,325 @@
+package blackbox
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what an AsyncTarget does with a new entry when its
+// queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the logging goroutine until there is room in the
+	// queue. No entries are dropped.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the entry being logged.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest queued entry to make room for the
+	// entry being logged.
+	OverflowDropOldest
+	// OverflowDropBelowLevel drops the entry being logged if it is below the
+	// drop level, and blocks otherwise.
+	OverflowDropBelowLevel
+)
+
+// AsyncTarget is a Target that wraps another target, passing entries to it
+// from a background goroutine through a bounded queue. This prevents a slow
+// target, such as one writing to a network socket, from stalling every
+// goroutine that logs.
+//
+// Flush and Close should be called before the program exits, otherwise queued
+// entries may be lost. Errors reported by the wrapped target are passed to the
+// handler set with Logger.OnTargetError from the background goroutine, or
+// written to stderr if there is none.
+type AsyncTarget struct {
+	target      Target
+	queue       chan asyncEntry
+	policy      atomic.Int64
+	dropLevel   AtomicLevel
+	dropped     atomic.Uint64
+	closed      bool
+	closedInner atomic.Bool
+	closedLock  sync.RWMutex
+	senders     sync.WaitGroup
+	stop        chan struct{}
+	stopOnce    sync.Once
+	enqueued    uint64
+	processed   uint64
+	waiters     []asyncWaiter
+	counterLock sync.Mutex
+	done        chan struct{}
+}
+
+type asyncEntry struct {
+	at        time.Time
+	loggerID  string
+	level     Level
+	values    []any
+	context   Ctx
+	getSource func() *Source
+	onError   func(Target, error)
+}
+
+type asyncWaiter struct {
+	processed uint64
+	done      chan struct{}
+}
+
+var _ Target = &AsyncTarget{}
+var _ TimedTarget = &AsyncTarget{}
+var _ Flusher = &AsyncTarget{}
+var _ Closer = &AsyncTarget{}
+
+// NewAsyncTarget creates an AsyncTarget that queues up to size entries for the
+// given target. The default overflow policy is OverflowBlock.
+func NewAsyncTarget(target Target, size int) *AsyncTarget {
+	if size < 1 {
+		size = 1
+	}
+	a := &AsyncTarget{
+		target: target,
+		queue:  make(chan asyncEntry, size),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	a.dropLevel.SetLevel(Warn)
+	go a.run()
+	return a
+}
+
+// SetOverflowPolicy sets what happens to new entries when the queue is full.
+func (a *AsyncTarget) SetOverflowPolicy(policy OverflowPolicy) *AsyncTarget {
+	a.policy.Store(int64(policy))
+	return a
+}
+
+// SetDropLevel sets the level used by OverflowDropBelowLevel. Entries below
+// this level are dropped when the queue is full. The default is Warn.
+func (a *AsyncTarget) SetDropLevel(level Level) *AsyncTarget {
+	a.dropLevel.SetLevel(level)
+	return a
+}
+
+// Dropped returns the number of entries that have been dropped, either due to
+// the overflow policy or because they were logged after Close.
+func (a *AsyncTarget) Dropped() uint64 {
+	return a.dropped.Load()
+}
+
+// Enabled reports whether the wrapped target accepts entries at the given
+// level. Targets that do not implement Enabler accept every level.
+func (a *AsyncTarget) Enabled(level Level) bool {
+	if enabler, ok := a.target.(Enabler); ok {
+		return enabler.Enabled(level)
+	}
+	return true
+}
+
+// UsesSource reports whether the wrapped target uses the source of entries.
+// Targets that do not implement SourceUser are assumed to.
+func (a *AsyncTarget) UsesSource() bool {
+	if sourceUser, ok := a.target.(SourceUser); ok {
+		return sourceUser.UsesSource()
+	}
+	return true
+}
+
+// Log queues the entry to be passed to the wrapped target.
+func (a *AsyncTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	a.enqueue(asyncEntry{
+		loggerID:  loggerID,
+		level:     level,
+		values:    values,
+		context:   context,
+		getSource: getSource,
+	})
+}
+
+// LogAt behaves the same as Log, but the given time is passed on to the
+// wrapped target if it implements TimedTarget. It always returns nil.
+func (a *AsyncTarget) LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	a.enqueue(asyncEntry{
+		at:        at,
+		loggerID:  loggerID,
+		level:     level,
+		values:    values,
+		context:   context,
+		getSource: getSource,
+	})
+	return nil
+}
+
+func (a *AsyncTarget) enqueue(entry asyncEntry) {
+	// The closed lock is only held while checking closed, never while
+	// waiting for room in the queue, so Close is not held up by a blocked
+	// sender. Senders are counted so the queue is only closed once they have
+	// all finished.
+	a.closedLock.RLock()
+	if a.closed {
+		a.closedLock.RUnlock()
+		a.dropped.Add(1)
+		return
+	}
+	a.senders.Add(1)
+	a.closedLock.RUnlock()
+	defer a.senders.Done()
+
+	switch OverflowPolicy(a.policy.Load()) {
+	case OverflowDropNewest:
+		if !a.trySend(entry) {
+			a.dropped.Add(1)
+		}
+
+	case OverflowDropOldest:
+		for !a.trySend(entry) {
+			select {
+			case <-a.queue:
+				a.dropped.Add(1)
+				a.markProcessed()
+			default:
+			}
+		}
+
+	case OverflowDropBelowLevel:
+		if a.dropLevel.Enabled(entry.level) {
+			a.send(entry)
+		} else if !a.trySend(entry) {
+			a.dropped.Add(1)
+		}
+
+	default:
+		a.send(entry)
+	}
+}
+
+// Flush blocks until every entry queued before Flush was called has been
+// passed to the wrapped target, or until ctx is done. If the wrapped target
+// implements Flusher, it is then flushed as well.
+func (a *AsyncTarget) Flush(ctx context.Context) error {
+	if err := a.drain(ctx); err != nil {
+		return err
+	}
+	if flusher, ok := a.target.(Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return nil
+}
+
+// Close stops accepting new entries, then waits for the queued entries to be
+// passed to the wrapped target, or until ctx is done. If the wrapped target
+// implements Closer, it is then closed as well, otherwise it is flushed if it
+// implements Flusher. Entries logged after Close are dropped.
+//
+// If ctx is done before the queue has drained, entries still waiting for room
+// in the queue are dropped, and Close returns ctx.Err(). The background
+// goroutine still passes the queued entries to the wrapped target and then
+// exits. Close can be called again to wait for it and close the wrapped
+// target.
+func (a *AsyncTarget) Close(ctx context.Context) error {
+	a.closedLock.Lock()
+	if !a.closed {
+		a.closed = true
+		go func() {
+			a.senders.Wait()
+			close(a.queue)
+		}()
+	}
+	a.closedLock.Unlock()
+
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		a.stopOnce.Do(func() { close(a.stop) })
+		return ctx.Err()
+	}
+
+	if !a.closedInner.CompareAndSwap(false, true) {
+		return nil
+	}
+	if closer, ok := a.target.(Closer); ok {
+		return closer.Close(ctx)
+	}
+	if flusher, ok := a.target.(Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return nil
+}
+
+// drain blocks until every entry queued before drain was called has been
+// passed to the wrapped target, or until ctx is done.
+func (a *AsyncTarget) drain(ctx context.Context) error {
+	a.counterLock.Lock()
+	if a.processed >= a.enqueued {
+		a.counterLock.Unlock()
+		return nil
+	}
+	waiter := asyncWaiter{
+		processed: a.enqueued,
+		done:      make(chan struct{}),
+	}
+	a.waiters = append(a.waiters, waiter)
+	a.counterLock.Unlock()
+
+	select {
+	case <-waiter.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// send queues the entry, blocking until there is room or Close gives up. The
+// entry is counted before it is queued so that it can never be processed
+// before it is counted.
+func (a *AsyncTarget) send(entry asyncEntry) {
+	a.counterLock.Lock()
+	a.enqueued++
+	a.counterLock.Unlock()
+	select {
+	case a.queue <- entry:
+	case <-a.stop:
+		a.dropped.Add(1)
+		a.markProcessed()
+	}
+}
+
+// trySend queues the entry if there is room and reports whether it did.
+func (a *AsyncTarget) trySend(entry asyncEntry) bool {
+	a.counterLock.Lock()
+	defer a.counterLock.Unlock()
+	select {
+	case a.queue <- entry:
+		a.enqueued++
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *AsyncTarget) run() {
+	defer close(a.done)
+	for entry := range a.queue {
+		if err := logTimedToTarget(a.target, entry.at, entry.loggerID, entry.level, entry.values, entry.context, entry.getSource); err != nil {
+			handleTargetError(entry.onError, a.target, err)
+		}
+		a.markProcessed()
+	}
+}
+
+func (a *AsyncTarget) markProcessed() {
+	a.counterLock.Lock()
+	a.processed++
+	waiters := a.waiters[:0]
+	for _, waiter := range a.waiters {
+		if a.processed >= waiter.processed {
+			close(waiter.done)
+		} else {
+			waiters = append(waiters, waiter)
+		}
+	}
+	a.waiters = waiters
+	a.counterLock.Unlock()
+}
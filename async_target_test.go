@@ -0,0 +1,227 @@
+package blackbox_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+// gatedTarget records entries, but only once its gate has been opened. This
+// lets tests fill an AsyncTarget's queue.
+type gatedTarget struct {
+	gate   chan struct{}
+	lock   sync.Mutex
+	logged []string
+	closes int
+}
+
+func newGatedTarget() *gatedTarget {
+	return &gatedTarget{gate: make(chan struct{})}
+}
+
+func (g *gatedTarget) Log(loggerID string, level blackbox.Level, values []any, context blackbox.Ctx, getSource func() *blackbox.Source) {
+	<-g.gate
+	g.lock.Lock()
+	g.logged = append(g.logged, values[0].(string))
+	g.lock.Unlock()
+}
+
+func (g *gatedTarget) Close(ctx context.Context) error {
+	g.lock.Lock()
+	g.closes++
+	g.lock.Unlock()
+	return nil
+}
+
+func (g *gatedTarget) Closes() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.closes
+}
+
+func (g *gatedTarget) Logged() []string {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return append([]string(nil), g.logged...)
+}
+
+func TestAsyncTarget(t *testing.T) {
+	testTarget := blackbox.NewTestTarget()
+	asyncTarget := blackbox.NewAsyncTarget(testTarget, 10)
+
+	logger := blackbox.New()
+	logger.AddTarget(asyncTarget)
+	logger.Info("Message", blackbox.Ctx{"key": "value"})
+
+	assert.NoError(t, asyncTarget.Close(context.Background()))
+
+	logged, ok := testTarget.LastLogged()
+
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Message", logged.Values[0])
+	assert.Equal(t, blackbox.Ctx{"key": "value"}, logged.Context)
+	assert.Contains(t, logged.Source.Function, "TestAsyncTarget")
+}
+
+func TestAsyncTargetDropNewest(t *testing.T) {
+	gatedTarget := newGatedTarget()
+	asyncTarget := blackbox.NewAsyncTarget(gatedTarget, 2).SetOverflowPolicy(blackbox.OverflowDropNewest)
+
+	// The first entry is taken by the background goroutine, which then blocks
+	// on the gate, leaving the queue to fill with the next two.
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"one"}, nil, nil)
+	time.Sleep(10 * time.Millisecond)
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"two"}, nil, nil)
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"three"}, nil, nil)
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"four"}, nil, nil)
+
+	close(gatedTarget.gate)
+	assert.NoError(t, asyncTarget.Flush(context.Background()))
+
+	assert.Equal(t, []string{"one", "two", "three"}, gatedTarget.Logged())
+	assert.Equal(t, uint64(1), asyncTarget.Dropped())
+}
+
+func TestAsyncTargetDropOldest(t *testing.T) {
+	gatedTarget := newGatedTarget()
+	asyncTarget := blackbox.NewAsyncTarget(gatedTarget, 2).SetOverflowPolicy(blackbox.OverflowDropOldest)
+
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"one"}, nil, nil)
+	time.Sleep(10 * time.Millisecond)
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"two"}, nil, nil)
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"three"}, nil, nil)
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"four"}, nil, nil)
+
+	close(gatedTarget.gate)
+	assert.NoError(t, asyncTarget.Flush(context.Background()))
+
+	assert.Equal(t, []string{"one", "three", "four"}, gatedTarget.Logged())
+	assert.Equal(t, uint64(1), asyncTarget.Dropped())
+}
+
+func TestAsyncTargetDropBelowLevel(t *testing.T) {
+	gatedTarget := newGatedTarget()
+	asyncTarget := blackbox.NewAsyncTarget(gatedTarget, 2).
+		SetOverflowPolicy(blackbox.OverflowDropBelowLevel).
+		SetDropLevel(blackbox.Error)
+
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"one"}, nil, nil)
+	time.Sleep(10 * time.Millisecond)
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"two"}, nil, nil)
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"three"}, nil, nil)
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"four"}, nil, nil)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(gatedTarget.gate)
+	}()
+	asyncTarget.Log("AAA-AAA", blackbox.Error, []any{"five"}, nil, nil)
+	assert.NoError(t, asyncTarget.Flush(context.Background()))
+
+	assert.Equal(t, []string{"one", "two", "three", "five"}, gatedTarget.Logged())
+	assert.Equal(t, uint64(1), asyncTarget.Dropped())
+}
+
+func TestAsyncTargetFlushDeadline(t *testing.T) {
+	gatedTarget := newGatedTarget()
+	asyncTarget := blackbox.NewAsyncTarget(gatedTarget, 2)
+
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"one"}, nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, asyncTarget.Flush(ctx), context.DeadlineExceeded)
+
+	close(gatedTarget.gate)
+	assert.NoError(t, asyncTarget.Close(context.Background()))
+
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"two"}, nil, nil)
+
+	assert.Equal(t, []string{"one"}, gatedTarget.Logged())
+	assert.Equal(t, uint64(1), asyncTarget.Dropped())
+}
+
+func TestAsyncTargetCloseDeadline(t *testing.T) {
+	gatedTarget := newGatedTarget()
+	asyncTarget := blackbox.NewAsyncTarget(gatedTarget, 2)
+
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"one"}, nil, nil)
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"two"}, nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, asyncTarget.Close(ctx), context.DeadlineExceeded)
+	assert.Equal(t, 0, gatedTarget.Closes())
+
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"three"}, nil, nil)
+
+	close(gatedTarget.gate)
+	assert.NoError(t, asyncTarget.Close(context.Background()))
+	assert.NoError(t, asyncTarget.Close(context.Background()))
+
+	assert.Equal(t, []string{"one", "two"}, gatedTarget.Logged())
+	assert.Equal(t, 1, gatedTarget.Closes())
+	assert.Equal(t, uint64(1), asyncTarget.Dropped())
+}
+
+func TestAsyncTargetOnTargetError(t *testing.T) {
+	logger := blackbox.New()
+	jsonTarget := blackbox.NewJSONTarget(failingWriter{}, failingWriter{})
+	logger.AddTarget(blackbox.NewAsyncTarget(jsonTarget, 10))
+
+	var lock sync.Mutex
+	var failedTargets []blackbox.Target
+	logger.OnTargetError(func(target blackbox.Target, err error) {
+		lock.Lock()
+		failedTargets = append(failedTargets, target)
+		lock.Unlock()
+	})
+
+	logger.Info("Message")
+	assert.NoError(t, logger.Close(context.Background()))
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []blackbox.Target{jsonTarget}, failedTargets)
+}
+
+func TestAsyncTargetCloseDeadlineWithBlockedSender(t *testing.T) {
+	gatedTarget := newGatedTarget()
+	asyncTarget := blackbox.NewAsyncTarget(gatedTarget, 1)
+
+	// The first entry is taken by the background goroutine, which then blocks
+	// on the gate, and the second fills the queue.
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"one"}, nil, nil)
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"two"}, nil, nil)
+
+	blocked := make(chan struct{})
+	go func() {
+		asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"three"}, nil, nil)
+		close(blocked)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, asyncTarget.Close(ctx), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Error("blocked sender was not released by Close")
+	}
+	asyncTarget.Log("AAA-AAA", blackbox.Info, []any{"four"}, nil, nil)
+
+	close(gatedTarget.gate)
+	assert.NoError(t, asyncTarget.Close(context.Background()))
+
+	assert.Equal(t, []string{"one", "two"}, gatedTarget.Logged())
+	assert.Equal(t, uint64(2), asyncTarget.Dropped())
+	assert.Equal(t, 1, gatedTarget.Closes())
+}
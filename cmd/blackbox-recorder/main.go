@@ -0,0 +1,97 @@
+// Command blackbox-recorder decodes the entries held in a blackbox recorder
+// file and writes them to stdout, oldest entry first.
+//
+// Usage:
+//
+//	blackbox-recorder [-json] <file>
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/RobertWHurst/blackbox"
+)
+
+func main() {
+	useJSON := flag.Bool("json", false, "output entries as newline separated json")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-json] <file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	entries, err := blackbox.ReadRecorderFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, entry := range entries {
+		if *useJSON {
+			if err := encoder.Encode(jsonEntry(entry)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			continue
+		}
+		fmt.Println(formatEntry(entry))
+	}
+}
+
+func jsonEntry(entry blackbox.RecordedEntry) map[string]any {
+	jsonData := map[string]any{
+		"time":     entry.Time,
+		"level":    entry.Level.String(),
+		"loggerID": entry.LoggerID,
+		"message":  entry.Message,
+	}
+	if len(entry.Context) != 0 {
+		jsonData["context"] = entry.Context
+	}
+	if entry.Source != nil {
+		jsonData["source"] = entry.Source
+	}
+	return jsonData
+}
+
+func formatEntry(entry blackbox.RecordedEntry) string {
+	levelStr := entry.Level.String()
+	for len(levelStr) < 7 {
+		levelStr += " "
+	}
+
+	str := fmt.Sprintf("%s %s %s %s",
+		entry.Time.Local().Format("2006-01-02 15:04:05.000 MST"),
+		entry.LoggerID,
+		levelStr,
+		entry.Message,
+	)
+	if len(entry.Context) != 0 {
+		str += " " + formatContext(entry.Context)
+	}
+	if entry.Source != nil {
+		str += fmt.Sprintf(" @=> %s:%d - %s", entry.Source.File, entry.Source.Line, entry.Source.Function)
+	}
+	return str
+}
+
+func formatContext(context blackbox.Ctx) string {
+	contextStrs := make([]string, 0, len(context))
+	for key, value := range context {
+		formattedValue := strings.Replace(fmt.Sprintf("%+v", value), "\n", "\\n", -1)
+		contextStrs = append(contextStrs, key+"="+formattedValue)
+	}
+	sort.Strings(contextStrs)
+	return strings.Join(contextStrs, " ")
+}
@@ -0,0 +1,215 @@
+package blackbox
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+type fieldKind uint8
+
+const (
+	fieldSkip fieldKind = iota
+	fieldString
+	fieldInt
+	fieldUint
+	fieldFloat
+	fieldBool
+	fieldDuration
+	fieldTime
+	fieldError
+	fieldAny
+)
+
+// Field is a typed key value pair that can be passed to the logging methods
+// alongside, or in place of, a Ctx. Fields are created with String, Int, Err
+// and the other field functions, and hold their value without boxing it in an
+// interface or copying it into a map. The pretty, json and logfmt targets
+// encode fields directly. Other targets receive them merged into the entry's
+// context.
+//
+//	logger.Info("saved", blackbox.String("user", id), blackbox.Int("bytes", n))
+type Field struct {
+	Key   string
+	kind  fieldKind
+	num   uint64
+	str   string
+	value any
+}
+
+// FieldTarget is an optional extension of Target for targets that encode
+// fields directly. When an entry has fields, the logger calls LogFields in
+// place of Log or TryLog, and handles any error returned in the same way.
+// Targets that do not implement FieldTarget receive the fields merged into
+// the entry's context.
+type FieldTarget interface {
+	Target
+	LogFields(loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) error
+}
+
+// String creates a field holding a string.
+func String(key string, value string) Field {
+	return Field{Key: key, kind: fieldString, str: value}
+}
+
+// Int creates a field holding an int.
+func Int(key string, value int) Field {
+	return Int64(key, int64(value))
+}
+
+// Int64 creates a field holding an int64.
+func Int64(key string, value int64) Field {
+	return Field{Key: key, kind: fieldInt, num: uint64(value)}
+}
+
+// Uint64 creates a field holding a uint64.
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, kind: fieldUint, num: value}
+}
+
+// Float64 creates a field holding a float64.
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: fieldFloat, num: math.Float64bits(value)}
+}
+
+// Bool creates a field holding a bool.
+func Bool(key string, value bool) Field {
+	var num uint64
+	if value {
+		num = 1
+	}
+	return Field{Key: key, kind: fieldBool, num: num}
+}
+
+// Duration creates a field holding a time.Duration.
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: fieldDuration, num: uint64(value)}
+}
+
+// Time creates a field holding a time.Time. The monotonic clock reading of
+// the time is not kept.
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, kind: fieldTime, num: uint64(value.UnixNano()), value: value.Location()}
+}
+
+// Err creates a field holding an error under the key error. If err is nil
+// the field is left out of the entry.
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error", kind: fieldSkip}
+	}
+	return Field{Key: "error", kind: fieldError, value: err}
+}
+
+// Any creates a field holding any value. Values of the types supported by the
+// other field functions are stored as if that function had been used.
+func Any(key string, value any) Field {
+	switch typedValue := value.(type) {
+	case string:
+		return String(key, typedValue)
+	case int:
+		return Int(key, typedValue)
+	case int64:
+		return Int64(key, typedValue)
+	case uint64:
+		return Uint64(key, typedValue)
+	case float64:
+		return Float64(key, typedValue)
+	case bool:
+		return Bool(key, typedValue)
+	case time.Duration:
+		return Duration(key, typedValue)
+	case time.Time:
+		return Time(key, typedValue)
+	}
+	return Field{Key: key, kind: fieldAny, value: value}
+}
+
+// Value returns the value held by the field.
+func (f Field) Value() any {
+	switch f.kind {
+	case fieldString:
+		return f.str
+	case fieldInt:
+		return int64(f.num)
+	case fieldUint:
+		return f.num
+	case fieldFloat:
+		return math.Float64frombits(f.num)
+	case fieldBool:
+		return f.num == 1
+	case fieldDuration:
+		return time.Duration(f.num)
+	case fieldTime:
+		return f.time()
+	}
+	return f.value
+}
+
+func (f Field) time() time.Time {
+	t := time.Unix(0, int64(f.num))
+	if location, ok := f.value.(*time.Location); ok {
+		t = t.In(location)
+	}
+	return t
+}
+
+// format returns the value of the field formatted as %+v would format it.
+func (f Field) format() string {
+	switch f.kind {
+	case fieldString:
+		return f.str
+	case fieldInt:
+		return strconv.FormatInt(int64(f.num), 10)
+	case fieldUint:
+		return strconv.FormatUint(f.num, 10)
+	case fieldFloat:
+		return strconv.FormatFloat(math.Float64frombits(f.num), 'g', -1, 64)
+	case fieldBool:
+		return strconv.FormatBool(f.num == 1)
+	case fieldDuration:
+		return time.Duration(f.num).String()
+	case fieldTime:
+		return f.time().String()
+	case fieldError:
+		return f.value.(error).Error()
+	}
+	return fmt.Sprintf("%+v", f.value)
+}
+
+// fieldsCtx returns a Ctx holding the value of each field, for targets that
+// do not implement FieldTarget.
+func fieldsCtx(fields []Field) Ctx {
+	context := make(Ctx, len(fields))
+	for _, field := range fields {
+		if field.kind != fieldSkip {
+			context[field.Key] = field.Value()
+		}
+	}
+	return context
+}
+
+// fieldShadowed reports whether the field at index is skipped, or is replaced
+// by a later field with the same key.
+func fieldShadowed(fields []Field, index int) bool {
+	if fields[index].kind == fieldSkip {
+		return true
+	}
+	for _, field := range fields[index+1:] {
+		if field.Key == fields[index].Key && field.kind != fieldSkip {
+			return true
+		}
+	}
+	return false
+}
+
+// hasField reports whether a field replaces the context value held under key.
+func hasField(fields []Field, key string) bool {
+	for _, field := range fields {
+		if field.Key == key && field.kind != fieldSkip {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package blackbox_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldValues(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	err := errors.New("failed")
+
+	assert.Equal(t, "bob", blackbox.String("user", "bob").Value())
+	assert.Equal(t, int64(3), blackbox.Int("count", 3).Value())
+	assert.Equal(t, int64(-3), blackbox.Int64("count", -3).Value())
+	assert.Equal(t, uint64(3), blackbox.Uint64("count", 3).Value())
+	assert.Equal(t, 0.5, blackbox.Float64("ratio", 0.5).Value())
+	assert.Equal(t, true, blackbox.Bool("ok", true).Value())
+	assert.Equal(t, time.Second, blackbox.Duration("elapsed", time.Second).Value())
+	assert.True(t, now.Equal(blackbox.Time("at", now).Value().(time.Time)))
+	assert.Equal(t, err, blackbox.Err(err).Value())
+	assert.Equal(t, "error", blackbox.Err(err).Key)
+	assert.Equal(t, []int{1, 2}, blackbox.Any("list", []int{1, 2}).Value())
+	assert.Equal(t, int64(3), blackbox.Any("count", 3).Value())
+}
+
+func TestLoggerFields(t *testing.T) {
+	logger := blackbox.NewWithCtx(blackbox.Ctx{"service": "api", "user": "alice"})
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	logger.Info("Saved", blackbox.String("user", "bob"), blackbox.Int("bytes", 42), blackbox.Err(nil))
+
+	logged, ok := testTarget.LastLogged()
+	assert.True(t, ok)
+	assert.Equal(t, []any{"Saved"}, logged.Values)
+	assert.Equal(t, blackbox.Ctx{"service": "api", "user": "bob", "bytes": int64(42)}, logged.Context)
+}
+
+func TestLoggerWithFields(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	requestLogger := logger.WithFields(blackbox.String("request", "a"))
+	firstLogger := requestLogger.WithFields(blackbox.Int("step", 1))
+	secondLogger := requestLogger.WithFields(blackbox.Int("step", 2))
+
+	firstLogger.Info("First")
+	secondLogger.Info("Second", blackbox.Ctx{"extra": true})
+	logger.Info("Third")
+
+	logged := testTarget.AllLogged()
+	assert.Equal(t, blackbox.Ctx{"request": "a", "step": int64(1)}, logged[0].Context)
+	assert.Equal(t, blackbox.Ctx{"request": "a", "step": int64(2), "extra": true}, logged[1].Context)
+	assert.Equal(t, blackbox.Ctx{}, logged[2].Context)
+}
+
+func TestLoggerMixedValues(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	values := []any{"A", blackbox.Ctx{"a": 1}, blackbox.String("b", "2"), "B", blackbox.Ctx{"c": 3}, "C"}
+	logger.Info(values...)
+
+	logged, _ := testTarget.LastLogged()
+	assert.Equal(t, []any{"A", "B", "C"}, logged.Values)
+	assert.Equal(t, blackbox.Ctx{"a": 1, "b": "2", "c": 3}, logged.Context)
+	assert.Equal(t, "A", values[0])
+	assert.Equal(t, blackbox.Ctx{"a": 1}, values[1])
+}
+
+func TestJSONTargetFields(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	logger := blackbox.NewWithCtx(blackbox.Ctx{"service": "api", "user": "alice"})
+	logger.AddTarget(blackbox.NewJSONTarget(outBuf, outBuf).ShowTimestamp(false))
+
+	logger.Info("Saved",
+		blackbox.String("user", "<bob>"),
+		blackbox.Int("bytes", 42),
+		blackbox.Float64("ratio", 1e-7),
+		blackbox.Bool("ok", true),
+		blackbox.Duration("elapsed", time.Millisecond),
+		blackbox.Time("at", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		blackbox.Err(errors.New("disk \"full\"")),
+		blackbox.Any("tags", []string{"a"}),
+		blackbox.Float64("nan", math.NaN()),
+	)
+
+	assert.Equal(t, `{"context":{"service":"api","user":"\u003cbob\u003e","bytes":42,"ratio":1e-7,"ok":true,"elapsed":1000000,"at":"2024-01-02T03:04:05Z","error":"disk \"full\"","tags":["a"],"nan":"NaN"},"level":"info","message":"Saved"}`+"\n", outBuf.String())
+}
+
+func TestJSONTargetFieldsDropValue(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	jsonTarget := blackbox.NewJSONTarget(outBuf, outBuf).ShowTimestamp(false).SetValueFallback(blackbox.DropValue)
+	logger := blackbox.New()
+	logger.AddTarget(jsonTarget)
+
+	logger.Info("Message", blackbox.Float64("nan", math.NaN()), blackbox.Int("n", 1))
+
+	assert.Equal(t, `{"context":{"n":1},"level":"info","message":"Message"}`+"\n", outBuf.String())
+}
+
+func TestLogfmtTargetFields(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	logger := blackbox.New()
+	logger.AddTarget(blackbox.NewLogfmtTarget(outBuf, outBuf).ShowTimestamp(false).ShowLevel(false))
+
+	logger.Info("Saved", blackbox.Ctx{"service": "api"}, blackbox.String("user", "bob smith"), blackbox.Duration("elapsed", 1500*time.Millisecond), blackbox.Any("request", blackbox.Ctx{"id": 7}))
+
+	assert.Equal(t, `msg=Saved elapsed=1.5s request.id=7 service=api user="bob smith"`+"\n", outBuf.String())
+}
+
+func TestPrettyTargetFields(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	logger := blackbox.New()
+	logger.AddTarget(blackbox.NewPrettyTarget(outBuf, outBuf).ShowTimestamp(false).UseColor(false))
+
+	logger.Info("Saved", blackbox.String("user", "bob"), blackbox.String("-hidden", "x"), blackbox.Bool("ok", true))
+
+	assert.Equal(t, "info    Saved ok=true user=bob\n", outBuf.String())
+}
+
+func TestRedactorFields(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	logger := blackbox.New()
+	logger.AddTarget(blackbox.NewLogfmtTarget(outBuf, outBuf).
+		ShowTimestamp(false).
+		ShowLevel(false).
+		SetRedactor(blackbox.NewRedactor()))
+
+	logger.Info("Login", blackbox.String("password", "hunter2"), blackbox.Int("api_key", 1234))
+
+	assert.NotContains(t, outBuf.String(), "hunter2")
+	assert.NotContains(t, outBuf.String(), "1234")
+}
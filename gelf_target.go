@@ -0,0 +1,322 @@
+package blackbox
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// GELFCompression is the compression a GELFTarget applies to UDP messages.
+type GELFCompression int
+
+const (
+	// GELFNoCompression sends messages uncompressed
+	GELFNoCompression GELFCompression = iota
+	// GELFGzip compresses messages with gzip
+	GELFGzip
+	// GELFZlib compresses messages with zlib
+	GELFZlib
+)
+
+const (
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+// GELFTarget is a Target that sends entries to Graylog, or any other server
+// accepting GELF 1.1 messages. The values of each entry make up the
+// short_message, context keys become additional fields prefixed with an
+// underscore, and levels are mapped to syslog severities in the same way as
+// SyslogTarget.
+//
+// Over UDP, messages are compressed, and split into chunks if they are larger
+// than the chunk size. Over TCP or TLS, messages are sent uncompressed and
+// delimited with a null byte, as Graylog expects.
+type GELFTarget struct {
+	network      string
+	conn         *reconnectingConn
+	hostname     string
+	compression  GELFCompression
+	chunkSize    int
+	showLoggerID atomic.Bool
+	useSource    atomic.Bool
+	level        AtomicLevel
+}
+
+var _ ErrorTarget = &GELFTarget{}
+var _ TimedTarget = &GELFTarget{}
+var _ Closer = &GELFTarget{}
+
+// NewGELFTarget creates a GELFTarget that sends entries to the GELF input at
+// address. The network may be "udp", "tcp" or "tls". UDP messages are
+// compressed with gzip and split into chunks of 1420 bytes by default.
+func NewGELFTarget(network string, address string) *GELFTarget {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return &GELFTarget{
+		network:     network,
+		conn:        newReconnectingConn(network, address),
+		hostname:    hostname,
+		compression: GELFGzip,
+		chunkSize:   1420,
+	}
+}
+
+// SetLevel sets the minimum log level that GELFTarget will send. Note that
+// this setting is independent of the log level set on the logger itself.
+func (g *GELFTarget) SetLevel(level Level) *GELFTarget {
+	g.level.SetLevel(level)
+	return g
+}
+
+// SetHostname sets the host field of each message. The default is the
+// hostname reported by the operating system.
+func (g *GELFTarget) SetHostname(hostname string) *GELFTarget {
+	g.hostname = hostname
+	return g
+}
+
+// SetCompression sets the compression used for UDP messages. The default is
+// GELFGzip.
+func (g *GELFTarget) SetCompression(compression GELFCompression) *GELFTarget {
+	g.compression = compression
+	return g
+}
+
+// SetChunkSize sets the maximum size of each UDP datagram. Larger messages are
+// split into up to 128 chunks. The default of 1420 bytes suits most networks;
+// 8192 may be used on networks that allow larger datagrams.
+func (g *GELFTarget) SetChunkSize(size int) *GELFTarget {
+	g.chunkSize = size
+	return g
+}
+
+// SetTLSConfig sets the TLS configuration used by the "tls" network.
+func (g *GELFTarget) SetTLSConfig(config *tls.Config) *GELFTarget {
+	g.conn.setTLSConfig(config)
+	return g
+}
+
+// SetTimeout sets how long connecting to the server, and writing each
+// message, may take before failing. The default is 5 seconds.
+func (g *GELFTarget) SetTimeout(timeout time.Duration) *GELFTarget {
+	g.conn.setTimeout(timeout)
+	return g
+}
+
+// SetBackoff sets how long the target waits before reconnecting after a
+// failed connection attempt. The wait starts at min and doubles with each
+// failed attempt up to max. The defaults are 100 milliseconds and 30 seconds.
+func (g *GELFTarget) SetBackoff(min time.Duration, max time.Duration) *GELFTarget {
+	g.conn.setBackoff(min, max)
+	return g
+}
+
+// ShowLoggerID will enable or disable the inclusion of a _logger_id field
+// depending on the boolean value passed.
+func (g *GELFTarget) ShowLoggerID(b bool) *GELFTarget {
+	g.showLoggerID.Store(b)
+	return g
+}
+
+// UseSource will enable or disable the inclusion of _file, _line and
+// _function fields depending on the boolean value passed.
+func (g *GELFTarget) UseSource(b bool) *GELFTarget {
+	g.useSource.Store(b)
+	return g
+}
+
+// Enabled reports whether GELFTarget accepts entries at the given level.
+func (g *GELFTarget) Enabled(level Level) bool {
+	return g.level.Enabled(level)
+}
+
+// UsesSource reports whether GELFTarget includes the source of entries.
+func (g *GELFTarget) UsesSource() bool {
+	return g.useSource.Load()
+}
+
+// Log takes a Level and series of values, then sends them to the server as a
+// GELF message. Errors are written to stderr.
+func (g *GELFTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	if err := g.TryLog(loggerID, level, values, context, getSource); err != nil {
+		reportTargetError(g, err)
+	}
+}
+
+// TryLog behaves the same as Log, but returns any error encountered while
+// encoding or sending the message.
+func (g *GELFTarget) TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return g.LogAt(time.Now(), loggerID, level, values, context, getSource)
+}
+
+// LogAt behaves the same as TryLog, but uses the given time for the entry.
+func (g *GELFTarget) LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	if !g.level.Enabled(level) {
+		return nil
+	}
+
+	strValues := make([]string, 0)
+	for _, value := range values {
+		strValues = append(strValues, fmt.Sprintf("%+v", value))
+	}
+	message := strings.Join(strValues, " ")
+
+	gelfData := make(map[string]any, len(context)+8)
+	addGELFFields(gelfData, "", context)
+	gelfData["version"] = "1.1"
+	gelfData["host"] = g.hostname
+	gelfData["timestamp"] = float64(at.UnixMicro()) / 1e6
+	gelfData["level"] = syslogSeverity(level)
+	if shortMessage, _, ok := strings.Cut(message, "\n"); ok {
+		gelfData["short_message"] = shortMessage
+		gelfData["full_message"] = message
+	} else {
+		gelfData["short_message"] = message
+	}
+	if g.showLoggerID.Load() {
+		gelfData["_logger_id"] = loggerID
+	}
+	if g.useSource.Load() && getSource != nil {
+		if source := getSource(); source != nil {
+			gelfData["_file"] = source.File
+			gelfData["_line"] = source.Line
+			gelfData["_function"] = source.Function
+		}
+	}
+
+	gelfBytes, err := json.Marshal(gelfData)
+	if err != nil {
+		return err
+	}
+
+	if g.network != "udp" && g.network != "udp4" && g.network != "udp6" {
+		return g.conn.write(append(gelfBytes, 0))
+	}
+
+	gelfBytes, err = g.compress(gelfBytes)
+	if err != nil {
+		return err
+	}
+	chunks, err := g.chunk(gelfBytes)
+	if err != nil {
+		return err
+	}
+	return g.conn.write(chunks...)
+}
+
+// Close closes the connection to the server.
+func (g *GELFTarget) Close(ctx context.Context) error {
+	return g.conn.close()
+}
+
+func (g *GELFTarget) compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	switch g.compression {
+	case GELFGzip:
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+	case GELFZlib:
+		writer := zlib.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return data, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// chunk splits data into GELF chunks if it is larger than the chunk size.
+// Each chunk starts with the magic bytes 0x1e 0x0f, an 8 byte message ID, the
+// sequence number of the chunk, and the number of chunks.
+func (g *GELFTarget) chunk(data []byte) ([][]byte, error) {
+	if len(data) <= g.chunkSize {
+		return [][]byte{data}, nil
+	}
+
+	chunkDataSize := g.chunkSize - gelfChunkHeaderSize
+	if chunkDataSize < 1 {
+		return nil, fmt.Errorf("gelf chunk size %d is too small", g.chunkSize)
+	}
+	count := (len(data) + chunkDataSize - 1) / chunkDataSize
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("gelf message of %d bytes needs %d chunks, more than the maximum of %d", len(data), count, gelfMaxChunks)
+	}
+
+	messageID := make([]byte, 8)
+	if _, err := rand.Read(messageID); err != nil {
+		return nil, err
+	}
+
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunkDataSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := make([]byte, 0, gelfChunkHeaderSize+end-i*chunkDataSize)
+		chunk = append(chunk, 0x1e, 0x0f)
+		chunk = append(chunk, messageID...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, data[i*chunkDataSize:end]...)
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// gelfFieldName converts a context key into an additional field name. Field
+// names may only contain letters, digits, underscores, dashes and dots, and
+// the name _id is reserved.
+func gelfFieldName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' && c != '-' && c != '.' {
+			name[i] = '_'
+		}
+	}
+	if string(name) == "id" {
+		return "_ctx_id"
+	}
+	return "_" + string(name)
+}
+
+// addGELFFields adds the context as additional fields, flattening nested Ctx
+// values. GELF only allows strings and numbers as field values, so other
+// values are formatted as strings.
+func addGELFFields(gelfData map[string]any, prefix string, context Ctx) {
+	for key, value := range context {
+		if nestedCtx, ok := value.(Ctx); ok {
+			addGELFFields(gelfData, prefix+key+"_", nestedCtx)
+			continue
+		}
+		switch reflect.ValueOf(value).Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		default:
+			value = fmt.Sprintf("%+v", value)
+		}
+		gelfData[gelfFieldName(prefix+key)] = value
+	}
+}
@@ -0,0 +1,177 @@
+package blackbox_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func readDatagram(t *testing.T, conn net.PacketConn) []byte {
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	assert.NoError(t, err)
+	return buf[:n]
+}
+
+func TestGELFTargetUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	gelfTarget := blackbox.NewGELFTarget("udp", conn.LocalAddr().String()).
+		SetHostname("host-1").
+		ShowLoggerID(true).
+		UseSource(true)
+	defer gelfTarget.Close(context.Background())
+
+	err = gelfTarget.TryLog("AAA-AAA", blackbox.Warn, []any{"Hello", "World\nDetails"}, blackbox.Ctx{
+		"user":      "bob",
+		"count":     3,
+		"ok":        true,
+		"id":        "abc",
+		"bad key":   "x",
+		"request":   blackbox.Ctx{"id": 42},
+		"timestamp": "not the timestamp",
+	}, func() *blackbox.Source {
+		return &blackbox.Source{File: "main.go", Line: 12, Function: "main.main"}
+	})
+	assert.NoError(t, err)
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(readDatagram(t, conn)))
+	assert.NoError(t, err)
+	gelfBytes, err := io.ReadAll(gzipReader)
+	assert.NoError(t, err)
+
+	var message map[string]any
+	assert.NoError(t, json.Unmarshal(gelfBytes, &message))
+
+	assert.IsType(t, float64(0), message["timestamp"])
+	delete(message, "timestamp")
+	assert.Equal(t, map[string]any{
+		"version":       "1.1",
+		"host":          "host-1",
+		"short_message": "Hello World",
+		"full_message":  "Hello World\nDetails",
+		"level":         float64(4),
+		"_user":         "bob",
+		"_count":        float64(3),
+		"_ok":           "true",
+		"_ctx_id":       "abc",
+		"_bad_key":      "x",
+		"_request_id":   float64(42),
+		"_timestamp":    "not the timestamp",
+		"_logger_id":    "AAA-AAA",
+		"_file":         "main.go",
+		"_line":         float64(12),
+		"_function":     "main.main",
+	}, message)
+}
+
+func TestGELFTargetChunking(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	gelfTarget := blackbox.NewGELFTarget("udp", conn.LocalAddr().String()).
+		SetCompression(blackbox.GELFZlib).
+		SetChunkSize(100)
+	defer gelfTarget.Close(context.Background())
+
+	// Random looking data so that compression can not shrink it below the
+	// chunk size.
+	var large strings.Builder
+	for i := 0; i < 200; i++ {
+		large.WriteString(string(rune('a' + (i*7919)%26)))
+		large.WriteString(string(rune('A' + (i*104729)%26)))
+	}
+	assert.NoError(t, gelfTarget.TryLog("AAA-AAA", blackbox.Info, []any{large.String()}, nil, nil))
+
+	first := readDatagram(t, conn)
+	assert.Equal(t, []byte{0x1e, 0x0f}, first[:2])
+	assert.LessOrEqual(t, len(first), 100)
+	count := int(first[11])
+	assert.Greater(t, count, 1)
+
+	chunks := make([][]byte, count)
+	chunks[first[10]] = first[12:]
+	for i := 1; i < count; i++ {
+		chunk := readDatagram(t, conn)
+		assert.Equal(t, first[2:10], chunk[2:10])
+		assert.Equal(t, byte(count), chunk[11])
+		chunks[chunk[10]] = chunk[12:]
+	}
+
+	zlibReader, err := zlib.NewReader(bytes.NewReader(bytes.Join(chunks, nil)))
+	assert.NoError(t, err)
+	gelfBytes, err := io.ReadAll(zlibReader)
+	assert.NoError(t, err)
+
+	var message map[string]any
+	assert.NoError(t, json.Unmarshal(gelfBytes, &message))
+	assert.Equal(t, large.String(), message["short_message"])
+}
+
+func TestGELFTargetTooManyChunks(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	gelfTarget := blackbox.NewGELFTarget("udp", conn.LocalAddr().String()).
+		SetCompression(blackbox.GELFNoCompression).
+		SetChunkSize(20)
+
+	err = gelfTarget.TryLog("AAA-AAA", blackbox.Info, []any{strings.Repeat("a", 2000)}, nil, nil)
+	assert.ErrorContains(t, err, "more than the maximum of 128")
+}
+
+func TestGELFTargetTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	messages := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			message, err := reader.ReadString(0)
+			if err != nil {
+				return
+			}
+			messages <- strings.TrimSuffix(message, "\x00")
+		}
+	}()
+
+	gelfTarget := blackbox.NewGELFTarget("tcp", listener.Addr().String())
+	defer gelfTarget.Close(context.Background())
+
+	assert.NoError(t, gelfTarget.TryLog("AAA-AAA", blackbox.Error, []any{"First"}, nil, nil))
+	assert.NoError(t, gelfTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Second"}, nil, nil))
+
+	for _, expected := range []string{"First", "Second"} {
+		select {
+		case message := <-messages:
+			var gelfData map[string]any
+			assert.NoError(t, json.Unmarshal([]byte(message), &gelfData))
+			assert.Equal(t, expected, gelfData["short_message"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for gelf message")
+		}
+	}
+}
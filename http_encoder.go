@@ -0,0 +1,237 @@
+package blackbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// HTTPEntry is a log entry batched by an HTTPTarget. Its message has already
+// been formatted from the logged values, and its context only holds values
+// that can be encoded as json.
+type HTTPEntry struct {
+	Time     time.Time
+	LoggerID string
+	Level    Level
+	Message  string
+	Context  Ctx
+	Source   *Source
+}
+
+// HTTPEncoder encodes a batch of entries into the body of a request sent by
+// an HTTPTarget.
+type HTTPEncoder interface {
+	// ContentType returns the Content-Type header of the request
+	ContentType() string
+	// Encode returns the request body for a batch of entries
+	Encode(entries []HTTPEntry) ([]byte, error)
+}
+
+// NDJSONEncoder is an HTTPEncoder that encodes entries as newline delimited
+// json, one entry per line, in the same shape JSONTarget writes them.
+type NDJSONEncoder struct{}
+
+var _ HTTPEncoder = &NDJSONEncoder{}
+
+// NewNDJSONEncoder creates an NDJSONEncoder
+func NewNDJSONEncoder() *NDJSONEncoder {
+	return &NDJSONEncoder{}
+}
+
+// ContentType returns application/x-ndjson
+func (n *NDJSONEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// Encode returns the entries as newline delimited json
+func (n *NDJSONEncoder) Encode(entries []HTTPEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		jsonData := httpEntryData(entry)
+		jsonData["time"] = entry.Time.Local().Format(time.RFC3339)
+		if err := appendJSONLine(&buf, jsonData); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// ElasticsearchEncoder is an HTTPEncoder that encodes entries for the
+// Elasticsearch _bulk API. Each entry is indexed as a document with an
+// @timestamp field.
+type ElasticsearchEncoder struct {
+	index string
+}
+
+var _ HTTPEncoder = &ElasticsearchEncoder{}
+
+// NewElasticsearchEncoder creates an ElasticsearchEncoder that indexes
+// entries into the given index, or data stream.
+func NewElasticsearchEncoder(index string) *ElasticsearchEncoder {
+	return &ElasticsearchEncoder{index: index}
+}
+
+// ContentType returns application/x-ndjson
+func (e *ElasticsearchEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// Encode returns a _bulk request body creating a document for each entry
+func (e *ElasticsearchEncoder) Encode(entries []HTTPEntry) ([]byte, error) {
+	action := map[string]any{"create": map[string]any{"_index": e.index}}
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		if err := appendJSONLine(&buf, action); err != nil {
+			return nil, err
+		}
+		jsonData := httpEntryData(entry)
+		jsonData["@timestamp"] = entry.Time.UTC().Format(time.RFC3339Nano)
+		if err := appendJSONLine(&buf, jsonData); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// LokiEncoder is an HTTPEncoder that encodes entries for the Grafana Loki
+// push API. Entries are grouped into streams by their labels, which are made
+// up of the static labels, the level of the entry, and the values of the
+// selected context keys. Each line holds the message and the remaining
+// context as json.
+//
+// Loki label names may only contain letters, digits and underscores, and may
+// not start with a digit. Other characters in context keys and static label
+// names are replaced with underscores, so the key http.method becomes the
+// label http_method.
+type LokiEncoder struct {
+	labelKeys    []string
+	staticLabels map[string]string
+}
+
+var _ HTTPEncoder = &LokiEncoder{}
+
+// NewLokiEncoder creates a LokiEncoder that uses the values of the given
+// context keys as stream labels.
+func NewLokiEncoder(labelKeys ...string) *LokiEncoder {
+	return &LokiEncoder{
+		labelKeys:    labelKeys,
+		staticLabels: map[string]string{},
+	}
+}
+
+// SetStaticLabel sets a label added to every stream, such as the name of the
+// application.
+func (l *LokiEncoder) SetStaticLabel(name string, value string) *LokiEncoder {
+	l.staticLabels[name] = value
+	return l
+}
+
+// ContentType returns application/json
+func (l *LokiEncoder) ContentType() string {
+	return "application/json"
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Encode returns a push API request body for the entries
+func (l *LokiEncoder) Encode(entries []HTTPEntry) ([]byte, error) {
+	streams := make([]*lokiStream, 0)
+	streamsByKey := make(map[string]*lokiStream)
+
+	for _, entry := range entries {
+		labels := make(map[string]string, len(l.staticLabels)+len(l.labelKeys)+1)
+		for name, value := range l.staticLabels {
+			labels[lokiLabelName(name)] = value
+		}
+		labels["level"] = entry.Level.String()
+
+		lineContext := entry.Context.Extend(nil)
+		for _, key := range l.labelKeys {
+			if value, ok := entry.Context[key]; ok {
+				labels[lokiLabelName(key)] = stringifyLabel(value)
+				delete(lineContext, key)
+			}
+		}
+		entry.Context = lineContext
+
+		streamKey, err := json.Marshal(labels)
+		if err != nil {
+			return nil, err
+		}
+		stream, ok := streamsByKey[string(streamKey)]
+		if !ok {
+			stream = &lokiStream{Stream: labels, Values: make([][2]string, 0)}
+			streamsByKey[string(streamKey)] = stream
+			streams = append(streams, stream)
+		}
+
+		line, err := json.Marshal(httpEntryData(entry))
+		if err != nil {
+			return nil, err
+		}
+		stream.Values = append(stream.Values, [2]string{
+			strconv.FormatInt(entry.Time.UnixNano(), 10),
+			string(line),
+		})
+	}
+
+	return json.Marshal(map[string]any{"streams": streams})
+}
+
+// lokiLabelName converts name into a valid Loki label name by replacing each
+// character other than a letter, digit or underscore with an underscore, and
+// prefixing names that start with a digit with an underscore.
+func lokiLabelName(name string) string {
+	label := []byte(name)
+	for i, c := range label {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' {
+			label[i] = '_'
+		}
+	}
+	if len(label) == 0 || (label[0] >= '0' && label[0] <= '9') {
+		label = append([]byte("_"), label...)
+	}
+	return string(label)
+}
+
+// httpEntryData returns the fields shared by the json based encoders.
+func httpEntryData(entry HTTPEntry) map[string]any {
+	jsonData := map[string]any{
+		"level":   entry.Level.String(),
+		"message": entry.Message,
+		"context": entry.Context,
+	}
+	if entry.LoggerID != "" {
+		jsonData["loggerID"] = entry.LoggerID
+	}
+	if entry.Source != nil {
+		jsonData["source"] = entry.Source
+	}
+	return jsonData
+}
+
+func appendJSONLine(buf *bytes.Buffer, value any) error {
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	buf.Write(jsonBytes)
+	buf.WriteByte('\n')
+	return nil
+}
+
+func stringifyLabel(value any) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(jsonBytes)
+}
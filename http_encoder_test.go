@@ -0,0 +1,117 @@
+package blackbox_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+var httpEntries = []blackbox.HTTPEntry{
+	{
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Level:   blackbox.Info,
+		Message: "First",
+		Context: blackbox.Ctx{"service": "api", "user": "bob"},
+	},
+	{
+		Time:     time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC),
+		LoggerID: "AAA-AAA",
+		Level:    blackbox.Error,
+		Message:  "Second",
+		Context:  blackbox.Ctx{"service": "api"},
+		Source:   &blackbox.Source{File: "main.go", Line: 12, Function: "main.main"},
+	},
+	{
+		Time:    time.Date(2024, 1, 2, 3, 4, 7, 0, time.UTC),
+		Level:   blackbox.Info,
+		Message: "Third",
+		Context: blackbox.Ctx{"service": "api"},
+	},
+}
+
+func TestNDJSONEncoder(t *testing.T) {
+	body, err := blackbox.NewNDJSONEncoder().Encode(httpEntries[:2])
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	assert.Len(t, lines, 2)
+	assert.JSONEq(t, `{
+		"time": "`+httpEntries[0].Time.Local().Format(time.RFC3339)+`",
+		"level": "info",
+		"message": "First",
+		"context": {"service": "api", "user": "bob"}
+	}`, lines[0])
+	assert.JSONEq(t, `{
+		"time": "`+httpEntries[1].Time.Local().Format(time.RFC3339)+`",
+		"level": "error",
+		"message": "Second",
+		"context": {"service": "api"},
+		"loggerID": "AAA-AAA",
+		"source": {"file": "main.go", "line": 12, "function": "main.main"}
+	}`, lines[1])
+}
+
+func TestElasticsearchEncoder(t *testing.T) {
+	body, err := blackbox.NewElasticsearchEncoder("logs").Encode(httpEntries[:1])
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	assert.Len(t, lines, 2)
+	assert.JSONEq(t, `{"create": {"_index": "logs"}}`, lines[0])
+	assert.JSONEq(t, `{
+		"@timestamp": "2024-01-02T03:04:05.000000006Z",
+		"level": "info",
+		"message": "First",
+		"context": {"service": "api", "user": "bob"}
+	}`, lines[1])
+}
+
+func TestLokiEncoder(t *testing.T) {
+	encoder := blackbox.NewLokiEncoder("service").SetStaticLabel("app", "myapp")
+	body, err := encoder.Encode(httpEntries)
+	assert.NoError(t, err)
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &push))
+	assert.Len(t, push.Streams, 2)
+
+	assert.Equal(t, map[string]string{"app": "myapp", "level": "info", "service": "api"}, push.Streams[0].Stream)
+	assert.Len(t, push.Streams[0].Values, 2)
+	assert.Equal(t, "1704164645000000006", push.Streams[0].Values[0][0])
+	assert.JSONEq(t, `{"level":"info","message":"First","context":{"user":"bob"}}`, push.Streams[0].Values[0][1])
+	assert.JSONEq(t, `{"level":"info","message":"Third","context":{}}`, push.Streams[0].Values[1][1])
+
+	assert.Equal(t, map[string]string{"app": "myapp", "level": "error", "service": "api"}, push.Streams[1].Stream)
+	assert.Len(t, push.Streams[1].Values, 1)
+
+	assert.Equal(t, blackbox.Ctx{"service": "api", "user": "bob"}, httpEntries[0].Context)
+}
+
+func TestLokiEncoderLabelNames(t *testing.T) {
+	encoder := blackbox.NewLokiEncoder("http.method", "2fa").SetStaticLabel("app-name", "myapp")
+	body, err := encoder.Encode([]blackbox.HTTPEntry{{
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Level:   blackbox.Info,
+		Message: "First",
+		Context: blackbox.Ctx{"http.method": "GET", "2fa": true},
+	}})
+	assert.NoError(t, err)
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+		} `json:"streams"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &push))
+	assert.Len(t, push.Streams, 1)
+	assert.Equal(t, map[string]string{"app_name": "myapp", "level": "info", "http_method": "GET", "_2fa": "true"}, push.Streams[0].Stream)
+}
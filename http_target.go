@@ -0,0 +1,690 @@
+package blackbox
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPTarget is a Target that batches entries and posts them to an HTTP
+// endpoint, such as the Loki push API or the Elasticsearch _bulk API. The body
+// of each request is produced by an HTTPEncoder.
+//
+// A batch is sent once it holds the maximum number of entries, reaches the
+// maximum size in bytes, or its oldest entry reaches the maximum age. Failed
+// requests are retried with jittered exponential backoff. If a spool directory
+// is set, batches that still fail are written to disk and sent once the
+// endpoint recovers.
+//
+// Memory use is bounded by the batch limits and the number of pending
+// batches. When every pending batch slot is taken, full batches are spooled
+// to disk, or dropped if there is no spool directory.
+type HTTPTarget struct {
+	url           string
+	encoder       HTTPEncoder
+	client        atomic.Pointer[http.Client]
+	headers       http.Header
+	headersLock   sync.RWMutex
+	useGzip       atomic.Bool
+	showLoggerID  atomic.Bool
+	useSource     atomic.Bool
+	level         AtomicLevel
+	valueFallback atomic.Int64
+	maxEntries    int
+	maxBytes      int
+	maxAge        time.Duration
+	retry         atomic.Pointer[httpRetry]
+	sender        func(body []byte) error
+	spoolDir      string
+	maxSpoolBytes int64
+	batch         []HTTPEntry
+	batchBytes    int
+	batchStarted  time.Time
+	batchLock     sync.Mutex
+	pending       chan httpBatch
+	dropped       atomic.Uint64
+	spoolLock     sync.Mutex
+	closed        bool
+	closedLock    sync.RWMutex
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// DefaultHTTPTimeout is the timeout of the http.Client used by HTTPTarget
+// unless another client is set with SetClient.
+const DefaultHTTPTimeout = 30 * time.Second
+
+type httpRetry struct {
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+type httpBatch struct {
+	entries []HTTPEntry
+	flushed chan struct{}
+}
+
+var _ ErrorTarget = &HTTPTarget{}
+var _ TimedTarget = &HTTPTarget{}
+var _ Flusher = &HTTPTarget{}
+var _ Closer = &HTTPTarget{}
+
+// NewHTTPTarget creates an HTTPTarget that posts batches of entries, encoded
+// with encoder, to url. By default batches are sent once they hold 1000
+// entries, reach 1MiB, or are a second old, and up to 8 batches may be
+// pending at once.
+func NewHTTPTarget(url string, encoder HTTPEncoder) *HTTPTarget {
+	h := &HTTPTarget{
+		url:           url,
+		encoder:       encoder,
+		headers:       make(http.Header),
+		maxEntries:    1000,
+		maxBytes:      1024 * 1024,
+		maxAge:        time.Second,
+		maxSpoolBytes: 100 * 1024 * 1024,
+		pending:       make(chan httpBatch, 8),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	h.SetClient(&http.Client{Timeout: DefaultHTTPTimeout})
+	h.SetRetry(5, 100*time.Millisecond, 10*time.Second)
+	go h.run()
+	return h
+}
+
+// SetLevel sets the minimum log level that HTTPTarget will send. Note that
+// this setting is independent of the log level set on the logger itself.
+func (h *HTTPTarget) SetLevel(level Level) *HTTPTarget {
+	h.level.SetLevel(level)
+	return h
+}
+
+// SetClient sets the http.Client used to send requests. The default is a
+// client with a timeout of DefaultHTTPTimeout. A client without a timeout
+// can stall the target's background goroutine indefinitely.
+func (h *HTTPTarget) SetClient(client *http.Client) *HTTPTarget {
+	h.client.Store(client)
+	return h
+}
+
+// SetHeader sets a header sent with every request, such as Authorization.
+func (h *HTTPTarget) SetHeader(key string, value string) *HTTPTarget {
+	h.headersLock.Lock()
+	defer h.headersLock.Unlock()
+	h.headers.Set(key, value)
+	return h
+}
+
+// UseGzip will enable or disable gzip compression of request bodies
+// depending on the boolean value passed.
+func (h *HTTPTarget) UseGzip(b bool) *HTTPTarget {
+	h.useGzip.Store(b)
+	return h
+}
+
+// ShowLoggerID will enable or disable the inclusion of the logger ID in each
+// entry depending on the boolean value passed.
+func (h *HTTPTarget) ShowLoggerID(b bool) *HTTPTarget {
+	h.showLoggerID.Store(b)
+	return h
+}
+
+// UseSource will enable or disable the inclusion of the source of each entry
+// depending on the boolean value passed.
+func (h *HTTPTarget) UseSource(b bool) *HTTPTarget {
+	h.useSource.Store(b)
+	return h
+}
+
+// SetValueFallback sets what happens to context values that can not be
+// encoded as json. The default is StringifyValue.
+func (h *HTTPTarget) SetValueFallback(fallback ValueFallback) *HTTPTarget {
+	h.valueFallback.Store(int64(fallback))
+	return h
+}
+
+// SetBatchLimits sets the number of entries, size in bytes, and age at which
+// a batch is sent. A limit of zero is ignored.
+func (h *HTTPTarget) SetBatchLimits(maxEntries int, maxBytes int, maxAge time.Duration) *HTTPTarget {
+	h.batchLock.Lock()
+	defer h.batchLock.Unlock()
+	h.maxEntries = maxEntries
+	h.maxBytes = maxBytes
+	h.maxAge = maxAge
+	return h
+}
+
+// SetRetry sets how many times a failed request is retried, and how long to
+// wait between attempts. The wait starts at minBackoff and doubles after each
+// attempt up to maxBackoff, with each wait randomly shortened by up to half
+// so that many processes do not retry in step.
+func (h *HTTPTarget) SetRetry(maxRetries int, minBackoff time.Duration, maxBackoff time.Duration) *HTTPTarget {
+	h.retry.Store(&httpRetry{
+		maxRetries: maxRetries,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+	})
+	return h
+}
+
+// SetSpool sets a directory that batches are written to when they can not be
+// sent, and the maximum number of bytes kept there. When the spool is full
+// the oldest batches are removed, and a batch larger than maxBytes is
+// dropped. Spooled batches are sent after the next successful request, on
+// Flush, and periodically while the target is idle.
+func (h *HTTPTarget) SetSpool(dir string, maxBytes int64) *HTTPTarget {
+	h.spoolLock.Lock()
+	defer h.spoolLock.Unlock()
+	h.spoolDir = dir
+	h.maxSpoolBytes = maxBytes
+	return h
+}
+
+// Dropped returns the number of entries that have been dropped, either
+// because there was no room for them or because a request failed and there
+// is no spool directory.
+func (h *HTTPTarget) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+// Enabled reports whether HTTPTarget accepts entries at the given level.
+func (h *HTTPTarget) Enabled(level Level) bool {
+	return h.level.Enabled(level)
+}
+
+// UsesSource reports whether HTTPTarget includes the source of entries.
+func (h *HTTPTarget) UsesSource() bool {
+	return h.useSource.Load()
+}
+
+// Log takes a Level and series of values, then adds them to the current
+// batch. Errors are written to stderr.
+func (h *HTTPTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	if err := h.TryLog(loggerID, level, values, context, getSource); err != nil {
+		reportTargetError(h, err)
+	}
+}
+
+// TryLog behaves the same as Log, but returns an error if the entry was
+// dropped. Errors sending batches happen in the background and are written to
+// stderr.
+func (h *HTTPTarget) TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return h.LogAt(time.Now(), loggerID, level, values, context, getSource)
+}
+
+// LogAt behaves the same as TryLog, but uses the given time for the entry.
+func (h *HTTPTarget) LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	if !h.level.Enabled(level) {
+		return nil
+	}
+
+	strValues := make([]string, 0)
+	for _, value := range values {
+		strValues = append(strValues, fmt.Sprintf("%+v", value))
+	}
+	entry := HTTPEntry{
+		Time:    at,
+		Level:   level,
+		Message: strings.Join(strValues, " "),
+		Context: context.Extend(nil),
+	}
+	if h.showLoggerID.Load() {
+		entry.LoggerID = loggerID
+	}
+	if h.useSource.Load() && getSource != nil {
+		entry.Source = getSource()
+	}
+
+	contextBytes, err := json.Marshal(entry.Context)
+	if err != nil {
+		entry.Context = encodableContext(entry.Context, ValueFallback(h.valueFallback.Load()))
+		contextBytes, _ = json.Marshal(entry.Context)
+	}
+	size := len(entry.Message) + len(contextBytes)
+
+	h.closedLock.RLock()
+	defer h.closedLock.RUnlock()
+
+	if h.closed {
+		h.dropped.Add(1)
+		return errors.New("http target is closed, entry dropped")
+	}
+
+	h.batchLock.Lock()
+	if len(h.batch) == 0 {
+		h.batchStarted = entry.Time
+	}
+	h.batch = append(h.batch, entry)
+	h.batchBytes += size
+	if (h.maxEntries > 0 && len(h.batch) >= h.maxEntries) || (h.maxBytes > 0 && h.batchBytes >= h.maxBytes) {
+		batch := h.takeBatch()
+		h.batchLock.Unlock()
+		return h.enqueue(batch)
+	}
+	h.batchLock.Unlock()
+	return nil
+}
+
+// Flush blocks until every entry logged before Flush was called has been
+// sent or spooled, or until ctx is done.
+func (h *HTTPTarget) Flush(ctx context.Context) error {
+	h.batchLock.Lock()
+	batch := httpBatch{entries: h.takeBatch(), flushed: make(chan struct{})}
+	h.batchLock.Unlock()
+
+	select {
+	case h.pending <- batch:
+	case <-h.done:
+		h.spoolOrDrop(batch.entries)
+		return nil
+	case <-ctx.Done():
+		h.spoolOrDrop(batch.entries)
+		return ctx.Err()
+	}
+
+	select {
+	case <-batch.flushed:
+		return nil
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close sends any batched entries, then stops the target's background
+// goroutine. Entries logged after Close are dropped.
+func (h *HTTPTarget) Close(ctx context.Context) error {
+	h.closedLock.Lock()
+	if h.closed {
+		h.closedLock.Unlock()
+		return nil
+	}
+	h.closed = true
+	h.closedLock.Unlock()
+
+	err := h.Flush(ctx)
+	close(h.stop)
+
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return err
+}
+
+// takeBatch removes and returns the current batch. The batch lock must be
+// held.
+func (h *HTTPTarget) takeBatch() []HTTPEntry {
+	batch := h.batch
+	h.batch = nil
+	h.batchBytes = 0
+	return batch
+}
+
+// enqueue passes a full batch to the background goroutine, spooling or
+// dropping it if every pending slot is taken.
+func (h *HTTPTarget) enqueue(entries []HTTPEntry) error {
+	select {
+	case h.pending <- httpBatch{entries: entries}:
+		return nil
+	default:
+	}
+	err := errors.New("http target has too many pending batches")
+	if h.spoolOrDrop(entries) {
+		return nil
+	}
+	return fmt.Errorf("%w, %d entries dropped", err, len(entries))
+}
+
+func (h *HTTPTarget) run() {
+	defer close(h.done)
+
+	interval := 250 * time.Millisecond
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastSpoolRetry := time.Now()
+
+	for {
+		select {
+		case batch := <-h.pending:
+			h.sendBatch(batch.entries)
+			if batch.flushed != nil {
+				// A successful batch has already retried the spool, but a
+				// flush with nothing batched must retry it itself.
+				if len(batch.entries) == 0 {
+					h.sendSpooled()
+				}
+				close(batch.flushed)
+			}
+
+		case <-ticker.C:
+			// Aged batches are only sent from here when nothing is pending,
+			// so that entries are sent in the order they were logged.
+			if len(h.pending) != 0 {
+				continue
+			}
+			h.batchLock.Lock()
+			var entries []HTTPEntry
+			if len(h.batch) != 0 && h.maxAge > 0 && time.Since(h.batchStarted) >= h.maxAge {
+				entries = h.takeBatch()
+			}
+			if newInterval := h.tickInterval(); newInterval != interval {
+				interval = newInterval
+				ticker.Reset(interval)
+			}
+			h.batchLock.Unlock()
+			if entries != nil {
+				h.sendBatch(entries)
+				lastSpoolRetry = time.Now()
+			} else if time.Since(lastSpoolRetry) >= h.retry.Load().maxBackoff {
+				// An idle target still empties its spool once the endpoint
+				// recovers, trying no more often than the maximum backoff.
+				h.sendSpooled()
+				lastSpoolRetry = time.Now()
+			}
+
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// tickInterval returns how often the age of the current batch is checked. The
+// batch lock must be held.
+func (h *HTTPTarget) tickInterval() time.Duration {
+	interval := h.maxAge / 4
+	if h.maxAge <= 0 {
+		interval = time.Second
+	}
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	return interval
+}
+
+func (h *HTTPTarget) sendBatch(entries []HTTPEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	body, err := h.encoder.Encode(entries)
+	if err != nil {
+		h.dropped.Add(uint64(len(entries)))
+		reportTargetError(h, err)
+		return
+	}
+
+	if err := h.sendWithRetry(body); err != nil {
+		if isPermanentError(err) {
+			h.dropped.Add(uint64(len(entries)))
+			reportTargetError(h, err)
+			return
+		}
+		if !h.spool(body) {
+			h.dropped.Add(uint64(len(entries)))
+		}
+		reportTargetError(h, err)
+		return
+	}
+
+	h.sendSpooled()
+}
+
+// spoolOrDrop encodes and spools entries that could not be sent, and reports
+// whether they were spooled. If they were not, they are counted as dropped.
+func (h *HTTPTarget) spoolOrDrop(entries []HTTPEntry) bool {
+	if len(entries) == 0 {
+		return true
+	}
+	if body, err := h.encoder.Encode(entries); err == nil && h.spool(body) {
+		return true
+	}
+	h.dropped.Add(uint64(len(entries)))
+	return false
+}
+
+type httpStatusError struct {
+	statusCode int
+	body       string
+	delay      time.Duration
+}
+
+func newHTTPStatusError(res *http.Response) *httpStatusError {
+	resBody, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	statusErr := &httpStatusError{statusCode: res.StatusCode, body: string(resBody)}
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		statusErr.delay = time.Duration(seconds) * time.Second
+	}
+	return statusErr
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("http target received status %d: %s", e.statusCode, e.body)
+}
+
+func (e *httpStatusError) retryable() bool {
+	return e.statusCode == http.StatusTooManyRequests || e.statusCode >= 500
+}
+
+func (e *httpStatusError) retryAfter() time.Duration {
+	return e.delay
+}
+
+// isPermanentError reports whether err is a failure that retrying can not
+// fix, such as a request rejected as malformed.
+func isPermanentError(err error) bool {
+	var retryErr interface{ retryable() bool }
+	return errors.As(err, &retryErr) && !retryErr.retryable()
+}
+
+// retryAfter returns how long the server asked for the request to be delayed
+// before it is retried, or zero if it did not.
+func retryAfter(err error) time.Duration {
+	var throttleErr interface{ retryAfter() time.Duration }
+	if errors.As(err, &throttleErr) {
+		return throttleErr.retryAfter()
+	}
+	return 0
+}
+
+func (h *HTTPTarget) sendWithRetry(body []byte) error {
+	retry := h.retry.Load()
+	backoff := retry.minBackoff
+	var err error
+	for attempt := 0; attempt <= retry.maxRetries; attempt++ {
+		if attempt != 0 {
+			wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+			if delay := retryAfter(err); delay > wait {
+				wait = delay
+			}
+			select {
+			case <-time.After(wait):
+			case <-h.stop:
+				return err
+			}
+			if backoff *= 2; backoff > retry.maxBackoff {
+				backoff = retry.maxBackoff
+			}
+		}
+
+		err = h.send(body)
+		if err == nil || isPermanentError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (h *HTTPTarget) send(body []byte) error {
+	if h.sender != nil {
+		return h.sender(body)
+	}
+	res, err := h.post(h.url, h.encoder.ContentType(), body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return newHTTPStatusError(res)
+	}
+	io.Copy(io.Discard, res.Body)
+	return nil
+}
+
+// post sends body to url with the target's headers, compressing it first if
+// gzip is enabled.
+func (h *HTTPTarget) post(url string, contentType string, body []byte) (*http.Response, error) {
+	if h.useGzip.Load() {
+		var err error
+		if body, err = gzipBytes(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	h.setHeaders(req)
+	req.Header.Set("Content-Type", contentType)
+	if h.useGzip.Load() {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	return h.client.Load().Do(req)
+}
+
+// setHeaders adds the headers set with SetHeader to req.
+func (h *HTTPTarget) setHeaders(req *http.Request) {
+	h.headersLock.RLock()
+	defer h.headersLock.RUnlock()
+	for key, values := range h.headers {
+		req.Header[key] = values
+	}
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	if _, err := gzipWriter.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// spool writes an encoded batch to the spool directory, removing the oldest
+// spooled batches if the spool is full. It reports whether the batch was
+// spooled. A batch larger than the whole spool is not spooled.
+func (h *HTTPTarget) spool(body []byte) bool {
+	h.spoolLock.Lock()
+	defer h.spoolLock.Unlock()
+
+	if h.spoolDir == "" {
+		return false
+	}
+	if h.maxSpoolBytes > 0 && int64(len(body)) > h.maxSpoolBytes {
+		return false
+	}
+	if err := os.MkdirAll(h.spoolDir, 0o755); err != nil {
+		reportTargetError(h, err)
+		return false
+	}
+
+	name := fmt.Sprintf("%020d.batch", time.Now().UnixNano())
+	if err := os.WriteFile(filepath.Join(h.spoolDir, name), body, 0o644); err != nil {
+		reportTargetError(h, err)
+		return false
+	}
+
+	files := h.spooledFiles()
+	var total int64
+	for i := len(files) - 1; i >= 0; i-- {
+		info, err := os.Stat(files[i])
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+		if total > h.maxSpoolBytes && h.maxSpoolBytes > 0 {
+			os.Remove(files[i])
+		}
+	}
+	return true
+}
+
+// sendSpooled sends spooled batches, oldest first, stopping at the first
+// failure. The spool lock is only held while reading each batch, so logging
+// goroutines spooling new batches are not held up by the requests.
+func (h *HTTPTarget) sendSpooled() {
+	for {
+		path, body, ok := h.nextSpooled()
+		if !ok {
+			return
+		}
+		if err := h.send(body); err != nil && !isPermanentError(err) {
+			return
+		}
+		h.spoolLock.Lock()
+		err := os.Remove(path)
+		h.spoolLock.Unlock()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			reportTargetError(h, err)
+			return
+		}
+	}
+}
+
+// nextSpooled returns the path and contents of the oldest spooled batch, or
+// false if there are none.
+func (h *HTTPTarget) nextSpooled() (string, []byte, bool) {
+	h.spoolLock.Lock()
+	defer h.spoolLock.Unlock()
+
+	if h.spoolDir == "" {
+		return "", nil, false
+	}
+	for _, path := range h.spooledFiles() {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		return path, body, true
+	}
+	return "", nil, false
+}
+
+// spooledFiles returns the paths of the spooled batches, oldest first. The
+// spool lock must be held.
+func (h *HTTPTarget) spooledFiles() []string {
+	entries, err := os.ReadDir(h.spoolDir)
+	if err != nil {
+		return nil
+	}
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".batch") {
+			paths = append(paths, filepath.Join(h.spoolDir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
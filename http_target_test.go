@@ -0,0 +1,389 @@
+package blackbox_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+type httpRecorder struct {
+	lock     sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+func (r *httpRecorder) record(req *http.Request) {
+	reader := io.Reader(req.Body)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(req.Body)
+		if err != nil {
+			panic(err)
+		}
+		reader = gzipReader
+	}
+	body, _ := io.ReadAll(reader)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, string(body))
+}
+
+func (r *httpRecorder) allBodies() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.bodies...)
+}
+
+func newHTTPServer(t *testing.T, status func() int) (*httptest.Server, *httpRecorder) {
+	recorder := &httpRecorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		code := status()
+		if code == http.StatusOK {
+			recorder.record(req)
+		}
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(server.Close)
+	return server, recorder
+}
+
+func ok() int {
+	return http.StatusOK
+}
+
+func TestHTTPTargetBatchByCount(t *testing.T) {
+	server, recorder := newHTTPServer(t, ok)
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).
+		SetBatchLimits(2, 0, time.Hour).
+		SetHeader("Authorization", "Bearer abc")
+	defer httpTarget.Close(context.Background())
+
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"First"}, blackbox.Ctx{"user": "bob"}, nil)
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"Second"}, nil, nil)
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"Third"}, nil, nil)
+
+	assert.Eventually(t, func() bool { return len(recorder.allBodies()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	lines := strings.Split(strings.TrimSuffix(recorder.allBodies()[0], "\n"), "\n")
+	assert.Len(t, lines, 2)
+
+	var first map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, "First", first["message"])
+	assert.Equal(t, "info", first["level"])
+	assert.Equal(t, map[string]any{"user": "bob"}, first["context"])
+	assert.Contains(t, first, "time")
+
+	recorder.lock.Lock()
+	assert.Equal(t, "Bearer abc", recorder.requests[0].Header.Get("Authorization"))
+	assert.Equal(t, "application/x-ndjson", recorder.requests[0].Header.Get("Content-Type"))
+	recorder.lock.Unlock()
+
+	assert.NoError(t, httpTarget.Flush(context.Background()))
+	bodies := recorder.allBodies()
+	assert.Len(t, bodies, 2)
+	assert.Contains(t, bodies[1], `"message":"Third"`)
+}
+
+func TestHTTPTargetConcurrentChanges(t *testing.T) {
+	server, recorder := newHTTPServer(t, ok)
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).
+		SetBatchLimits(1000, 0, 10*time.Millisecond)
+	defer httpTarget.Close(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				httpTarget.Log("AAA-AAA", blackbox.Info, []any{"Message"}, blackbox.Ctx{"count": j}, nil)
+				if j%10 == 0 {
+					httpTarget.Flush(context.Background())
+				}
+			}
+		}()
+	}
+
+	for j := 0; j < 100; j++ {
+		httpTarget.SetRetry(j%3, time.Millisecond, 10*time.Millisecond)
+		httpTarget.SetValueFallback(blackbox.ValueFallback(j % 2))
+		httpTarget.SetClient(&http.Client{Timeout: 5 * time.Second})
+		httpTarget.SetHeader("X-Count", strconv.Itoa(j))
+	}
+	wg.Wait()
+
+	assert.NoError(t, httpTarget.Flush(context.Background()))
+	count := 0
+	for _, body := range recorder.allBodies() {
+		count += strings.Count(body, "\n")
+	}
+	assert.Equal(t, 400, count)
+}
+
+func TestHTTPTargetBatchByBytes(t *testing.T) {
+	server, recorder := newHTTPServer(t, ok)
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).
+		SetBatchLimits(0, 10, time.Hour)
+	defer httpTarget.Close(context.Background())
+
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"A message longer than ten bytes"}, nil, nil)
+
+	assert.Eventually(t, func() bool { return len(recorder.allBodies()) == 1 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestHTTPTargetBatchByAge(t *testing.T) {
+	server, recorder := newHTTPServer(t, ok)
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).
+		SetBatchLimits(100, 0, 20*time.Millisecond)
+	defer httpTarget.Close(context.Background())
+
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil)
+
+	assert.Eventually(t, func() bool { return len(recorder.allBodies()) == 1 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestHTTPTargetGzip(t *testing.T) {
+	server, recorder := newHTTPServer(t, ok)
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).UseGzip(true)
+
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"Compressed"}, nil, nil)
+	assert.NoError(t, httpTarget.Close(context.Background()))
+
+	bodies := recorder.allBodies()
+	assert.Len(t, bodies, 1)
+	assert.Contains(t, bodies[0], `"message":"Compressed"`)
+}
+
+func TestHTTPTargetRetry(t *testing.T) {
+	var attempts atomic.Int32
+	server, recorder := newHTTPServer(t, func() int {
+		if attempts.Add(1) < 3 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).
+		SetRetry(5, time.Millisecond, 5*time.Millisecond)
+
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil)
+	assert.NoError(t, httpTarget.Close(context.Background()))
+
+	assert.Equal(t, int32(3), attempts.Load())
+	assert.Len(t, recorder.allBodies(), 1)
+	assert.Equal(t, uint64(0), httpTarget.Dropped())
+}
+
+func TestHTTPTargetNoRetryOnClientError(t *testing.T) {
+	var attempts atomic.Int32
+	server, _ := newHTTPServer(t, func() int {
+		attempts.Add(1)
+		return http.StatusBadRequest
+	})
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).
+		SetRetry(5, time.Millisecond, 5*time.Millisecond)
+
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil)
+	assert.NoError(t, httpTarget.Close(context.Background()))
+
+	assert.Equal(t, int32(1), attempts.Load())
+	assert.Equal(t, uint64(1), httpTarget.Dropped())
+}
+
+func TestHTTPTargetSpool(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	server, recorder := newHTTPServer(t, func() int {
+		if down.Load() {
+			return http.StatusInternalServerError
+		}
+		return http.StatusOK
+	})
+	spoolDir := t.TempDir()
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).
+		SetRetry(1, time.Millisecond, time.Millisecond).
+		SetSpool(spoolDir, 1024*1024)
+	defer httpTarget.Close(context.Background())
+
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"During outage"}, nil, nil)
+	assert.NoError(t, httpTarget.Flush(context.Background()))
+
+	spooled, err := os.ReadDir(spoolDir)
+	assert.NoError(t, err)
+	assert.Len(t, spooled, 1)
+	assert.Len(t, recorder.allBodies(), 0)
+
+	down.Store(false)
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"After outage"}, nil, nil)
+	assert.NoError(t, httpTarget.Flush(context.Background()))
+
+	bodies := recorder.allBodies()
+	assert.Len(t, bodies, 2)
+	assert.Contains(t, bodies[0], "After outage")
+	assert.Contains(t, bodies[1], "During outage")
+
+	spooled, err = os.ReadDir(spoolDir)
+	assert.NoError(t, err)
+	assert.Len(t, spooled, 0)
+	assert.Equal(t, uint64(0), httpTarget.Dropped())
+}
+
+func TestHTTPTargetSpoolRetriedWhenIdle(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	server, recorder := newHTTPServer(t, func() int {
+		if down.Load() {
+			return http.StatusInternalServerError
+		}
+		return http.StatusOK
+	})
+	spoolDir := t.TempDir()
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).
+		SetRetry(0, time.Millisecond, 20*time.Millisecond).
+		SetBatchLimits(0, 0, 20*time.Millisecond).
+		SetSpool(spoolDir, 1024*1024)
+	defer httpTarget.Close(context.Background())
+
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"First outage"}, nil, nil)
+	assert.NoError(t, httpTarget.Flush(context.Background()))
+	assert.Len(t, recorder.allBodies(), 0)
+
+	// Flush retries the spool even when nothing is batched.
+	down.Store(false)
+	assert.NoError(t, httpTarget.Flush(context.Background()))
+	bodies := recorder.allBodies()
+	assert.Len(t, bodies, 1)
+	assert.Contains(t, bodies[0], "First outage")
+
+	// Without a flush, the spool is retried while the target is idle.
+	down.Store(true)
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"Second outage"}, nil, nil)
+	assert.NoError(t, httpTarget.Flush(context.Background()))
+	down.Store(false)
+
+	assert.Eventually(t, func() bool {
+		return len(recorder.allBodies()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, recorder.allBodies()[1], "Second outage")
+}
+
+func TestHTTPTargetSpoolTooSmall(t *testing.T) {
+	server, _ := newHTTPServer(t, func() int {
+		return http.StatusInternalServerError
+	})
+	spoolDir := t.TempDir()
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).
+		SetRetry(0, time.Millisecond, time.Millisecond).
+		SetSpool(spoolDir, 16)
+	defer httpTarget.Close(context.Background())
+
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"Larger than the spool"}, nil, nil)
+	assert.NoError(t, httpTarget.Flush(context.Background()))
+
+	spooled, err := os.ReadDir(spoolDir)
+	assert.NoError(t, err)
+	assert.Len(t, spooled, 0)
+	assert.Equal(t, uint64(1), httpTarget.Dropped())
+}
+
+func TestHTTPTargetSpoolWhileSendingSpooled(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	sendingSpooled := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if strings.Contains(string(body), "During outage") {
+			close(sendingSpooled)
+			<-release
+		}
+	}))
+	defer server.Close()
+	spoolDir := t.TempDir()
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).
+		SetRetry(0, time.Millisecond, time.Millisecond).
+		SetSpool(spoolDir, 1024*1024)
+
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"During outage"}, nil, nil)
+	assert.NoError(t, httpTarget.Flush(context.Background()))
+
+	// The next batch succeeds, so the background goroutine starts sending the
+	// spooled batch, which the server holds on to.
+	down.Store(false)
+	httpTarget.SetBatchLimits(1, 0, time.Hour)
+	httpTarget.Log("AAA-AAA", blackbox.Info, []any{"After outage"}, nil, nil)
+	<-sendingSpooled
+
+	logged := make(chan struct{})
+	go func() {
+		for i := 0; i < 20; i++ {
+			httpTarget.Log("AAA-AAA", blackbox.Info, []any{"Overflow"}, nil, nil)
+		}
+		close(logged)
+	}()
+
+	select {
+	case <-logged:
+	case <-time.After(5 * time.Second):
+		t.Error("logging blocked while spooled batches were being sent")
+	}
+
+	close(release)
+	assert.NoError(t, httpTarget.Close(context.Background()))
+	assert.Equal(t, uint64(0), httpTarget.Dropped())
+}
+
+func TestHTTPTargetBoundedPending(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	httpTarget := blackbox.NewHTTPTarget(server.URL, blackbox.NewNDJSONEncoder()).
+		SetBatchLimits(1, 0, time.Hour)
+
+	var dropErr error
+	for i := 0; i < 20; i++ {
+		if err := httpTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil); err != nil {
+			dropErr = err
+		}
+	}
+	assert.ErrorContains(t, dropErr, "too many pending batches")
+	assert.Greater(t, httpTarget.Dropped(), uint64(0))
+
+	close(release)
+	assert.NoError(t, httpTarget.Close(context.Background()))
+
+	err := httpTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil)
+	assert.ErrorContains(t, err, "closed")
+}
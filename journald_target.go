@@ -0,0 +1,251 @@
+package blackbox
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultJournaldSocket is the path of the socket journald listens on for
+// entries sent with its native protocol.
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldTarget is a Target that writes entries to the systemd journal using
+// journald's native protocol. Each context key becomes a journal field, with
+// its name uppercased and any characters journald does not allow replaced
+// with underscores. Nested Ctx values are flattened, so a key id within a
+// request context becomes REQUEST_ID. Keys that would overwrite a field set
+// by the target itself, such as message or priority, are prefixed with X_.
+//
+// Levels are mapped to the PRIORITY field with the same severities as
+// SyslogTarget, and the CODE_FILE, CODE_LINE and CODE_FUNC fields are filled
+// from the source of each entry.
+type JournaldTarget struct {
+	socketPath   string
+	identifier   atomic.Pointer[string]
+	showLoggerID atomic.Bool
+	useSource    atomic.Bool
+	level        AtomicLevel
+	conn         *net.UnixConn
+	lock         sync.Mutex
+}
+
+var _ ErrorTarget = &JournaldTarget{}
+var _ Closer = &JournaldTarget{}
+
+// NewJournaldTarget creates a JournaldTarget that writes to the journald
+// socket at DefaultJournaldSocket.
+func NewJournaldTarget() *JournaldTarget {
+	target := &JournaldTarget{
+		socketPath: DefaultJournaldSocket,
+	}
+	target.SetIdentifier(filepath.Base(os.Args[0]))
+	target.useSource.Store(true)
+	return target
+}
+
+// SetLevel sets the minimum log level that JournaldTarget will write. Note
+// that this setting is independent of the log level set on the logger itself.
+func (j *JournaldTarget) SetLevel(level Level) *JournaldTarget {
+	j.level.SetLevel(level)
+	return j
+}
+
+// SetSocketPath sets the path of the socket entries are sent to. The default
+// is DefaultJournaldSocket.
+func (j *JournaldTarget) SetSocketPath(path string) *JournaldTarget {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.socketPath = path
+	if j.conn != nil {
+		j.conn.Close()
+		j.conn = nil
+	}
+	return j
+}
+
+// SetIdentifier sets the SYSLOG_IDENTIFIER field of each entry. The default
+// is the name of the running program.
+func (j *JournaldTarget) SetIdentifier(identifier string) *JournaldTarget {
+	j.identifier.Store(&identifier)
+	return j
+}
+
+// ShowLoggerID will enable or disable the inclusion of a BLACKBOX_LOGGER_ID
+// field depending on the boolean value passed.
+func (j *JournaldTarget) ShowLoggerID(b bool) *JournaldTarget {
+	j.showLoggerID.Store(b)
+	return j
+}
+
+// UseSource will enable or disable the CODE_FILE, CODE_LINE and CODE_FUNC
+// fields depending on the boolean value passed. They are enabled by default.
+func (j *JournaldTarget) UseSource(b bool) *JournaldTarget {
+	j.useSource.Store(b)
+	return j
+}
+
+// Enabled reports whether JournaldTarget accepts entries at the given level.
+func (j *JournaldTarget) Enabled(level Level) bool {
+	return j.level.Enabled(level)
+}
+
+// UsesSource reports whether JournaldTarget includes the source of entries.
+func (j *JournaldTarget) UsesSource() bool {
+	return j.useSource.Load()
+}
+
+// Log takes a Level and series of values, then writes them to the journal.
+// Errors are written to stderr.
+func (j *JournaldTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	if err := j.TryLog(loggerID, level, values, context, getSource); err != nil {
+		reportTargetError(j, err)
+	}
+}
+
+// TryLog behaves the same as Log, but returns any error encountered while
+// sending the entry to journald.
+func (j *JournaldTarget) TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	if !j.level.Enabled(level) {
+		return nil
+	}
+
+	strValues := make([]string, 0)
+	for _, value := range values {
+		strValues = append(strValues, fmt.Sprintf("%+v", value))
+	}
+
+	data := make([]byte, 0, 256)
+	data = appendJournalField(data, "MESSAGE", strings.Join(strValues, " "))
+	data = appendJournalField(data, "PRIORITY", strconv.Itoa(syslogSeverity(level)))
+	if identifier := *j.identifier.Load(); identifier != "" {
+		data = appendJournalField(data, "SYSLOG_IDENTIFIER", identifier)
+	}
+	if j.showLoggerID.Load() {
+		data = appendJournalField(data, "BLACKBOX_LOGGER_ID", loggerID)
+	}
+	if j.useSource.Load() && getSource != nil {
+		if source := getSource(); source != nil {
+			data = appendJournalField(data, "CODE_FILE", source.File)
+			data = appendJournalField(data, "CODE_LINE", strconv.Itoa(source.Line))
+			data = appendJournalField(data, "CODE_FUNC", source.Function)
+		}
+	}
+	data = appendJournalCtx(data, "", context)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.conn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: j.socketPath, Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		j.conn = conn
+	}
+
+	_, err := j.conn.Write(data)
+	if err != nil && isJournalMessageTooLarge(err) {
+		// Entries too large for a single datagram are written to a temporary
+		// file, and its descriptor is sent to journald in place of the entry.
+		return sendJournalFile(j.conn, data)
+	}
+	if err != nil {
+		// The socket may have gone away, for example if journald restarted,
+		// so the next entry dials it again.
+		j.conn.Close()
+		j.conn = nil
+	}
+	return err
+}
+
+// Close closes the connection to the journald socket.
+func (j *JournaldTarget) Close(ctx context.Context) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.conn == nil {
+		return nil
+	}
+	err := j.conn.Close()
+	j.conn = nil
+	return err
+}
+
+// journalFieldName converts key into a valid journal field name. Field names
+// may only contain uppercase letters, digits and underscores, may not start
+// with a digit or underscore, and may be at most 64 characters long. Names of
+// fields set by the target itself are prefixed with X_. An empty
+// string is returned if no valid name can be made from key.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	name = []byte(strings.TrimLeft(string(name), "_"))
+	if len(name) != 0 && name[0] >= '0' && name[0] <= '9' {
+		name = append([]byte("X_"), name...)
+	}
+	if journalTargetFields[string(name)] {
+		name = append([]byte("X_"), name...)
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return string(name)
+}
+
+// journalTargetFields holds the fields JournaldTarget sets itself, which
+// context keys may not overwrite.
+var journalTargetFields = map[string]bool{
+	"MESSAGE":            true,
+	"PRIORITY":           true,
+	"SYSLOG_IDENTIFIER":  true,
+	"BLACKBOX_LOGGER_ID": true,
+	"CODE_FILE":          true,
+	"CODE_LINE":          true,
+	"CODE_FUNC":          true,
+}
+
+func appendJournalCtx(data []byte, prefix string, context Ctx) []byte {
+	for key, value := range context {
+		if nestedCtx, ok := value.(Ctx); ok {
+			data = appendJournalCtx(data, prefix+key+"_", nestedCtx)
+			continue
+		}
+		name := journalFieldName(prefix + key)
+		if name == "" {
+			continue
+		}
+		data = appendJournalField(data, name, fmt.Sprintf("%+v", value))
+	}
+	return data
+}
+
+// appendJournalField appends a field in journald's native format. Values
+// without newlines are written as NAME=value, other values are written as the
+// name, a newline, the length of the value as a little endian 64 bit integer,
+// then the value.
+func appendJournalField(data []byte, name string, value string) []byte {
+	if !strings.Contains(value, "\n") {
+		data = append(data, name...)
+		data = append(data, '=')
+		data = append(data, value...)
+		return append(data, '\n')
+	}
+	data = append(data, name...)
+	data = append(data, '\n')
+	data = binary.LittleEndian.AppendUint64(data, uint64(len(value)))
+	data = append(data, value...)
+	return append(data, '\n')
+}
@@ -0,0 +1,54 @@
+//go:build linux
+
+package blackbox
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+func isJournalMessageTooLarge(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// sendJournalFile writes data to an unlinked temporary file, then sends its
+// descriptor to journald, which reads the entry from the file.
+func sendJournalFile(conn *net.UnixConn, data []byte) error {
+	file, err := os.CreateTemp("/dev/shm", "blackbox-journal-")
+	if err != nil {
+		file, err = os.CreateTemp("", "blackbox-journal-")
+		if err != nil {
+			return err
+		}
+	}
+	defer file.Close()
+
+	if err := os.Remove(file.Name()); err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// net.UnixConn refuses WriteMsgUnix on connected datagram sockets, so the
+	// descriptor is sent with sendmsg directly.
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sendErr error
+	err = rawConn.Write(func(fd uintptr) bool {
+		sendErr = syscall.Sendmsg(int(fd), nil, syscall.UnixRights(int(file.Fd())), nil, 0)
+		return sendErr != syscall.EAGAIN
+	})
+	if err != nil {
+		return err
+	}
+	return sendErr
+}
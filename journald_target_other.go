@@ -0,0 +1,16 @@
+//go:build !linux
+
+package blackbox
+
+import (
+	"errors"
+	"net"
+)
+
+func isJournalMessageTooLarge(err error) bool {
+	return false
+}
+
+func sendJournalFile(conn *net.UnixConn, data []byte) error {
+	return errors.New("journald is only supported on linux")
+}
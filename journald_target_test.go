@@ -0,0 +1,238 @@
+//go:build linux
+
+package blackbox_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func listenJournal(t *testing.T) (*net.UnixConn, string) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.NoError(t, err)
+	conn.SetReadBuffer(1024 * 1024)
+	t.Cleanup(func() { conn.Close() })
+	return conn, path
+}
+
+// parseJournalEntry parses an entry in journald's native format into a map of
+// field names to values.
+func parseJournalEntry(t *testing.T, data []byte) map[string]string {
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		lineEnd := bytes.IndexByte(data, '\n')
+		if !assert.NotEqual(t, -1, lineEnd) {
+			return fields
+		}
+		line := string(data[:lineEnd])
+		data = data[lineEnd+1:]
+		if name, value, ok := strings.Cut(line, "="); ok {
+			fields[name] = value
+			continue
+		}
+		length := binary.LittleEndian.Uint64(data[:8])
+		fields[line] = string(data[8 : 8+length])
+		data = data[8+length+1:]
+	}
+	return fields
+}
+
+func readJournalEntry(t *testing.T, conn *net.UnixConn) map[string]string {
+	buf := make([]byte, 1024*1024)
+	oob := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	assert.NoError(t, err)
+
+	if oobn == 0 {
+		return parseJournalEntry(t, buf[:n])
+	}
+
+	messages, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	assert.NoError(t, err)
+	fds, err := syscall.ParseUnixRights(&messages[0])
+	assert.NoError(t, err)
+	file := os.NewFile(uintptr(fds[0]), "journal-entry")
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	assert.NoError(t, err)
+	return parseJournalEntry(t, data)
+}
+
+func TestJournaldTarget(t *testing.T) {
+	conn, path := listenJournal(t)
+
+	journaldTarget := blackbox.NewJournaldTarget().
+		SetSocketPath(path).
+		SetIdentifier("myapp").
+		ShowLoggerID(true)
+	defer journaldTarget.Close(context.Background())
+
+	err := journaldTarget.TryLog("AAA-AAA", blackbox.Warn, []any{"Hello", "World"}, blackbox.Ctx{
+		"user-name": "bob",
+		"_private":  "value",
+		"2fa":       true,
+		"stack":     "line 1\nline 2",
+		"request":   blackbox.Ctx{"id": 42},
+	}, func() *blackbox.Source {
+		return &blackbox.Source{File: "main.go", Line: 12, Function: "main.main"}
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"MESSAGE":            "Hello World",
+		"PRIORITY":           "4",
+		"SYSLOG_IDENTIFIER":  "myapp",
+		"BLACKBOX_LOGGER_ID": "AAA-AAA",
+		"CODE_FILE":          "main.go",
+		"CODE_LINE":          "12",
+		"CODE_FUNC":          "main.main",
+		"USER_NAME":          "bob",
+		"PRIVATE":            "value",
+		"X_2FA":              "true",
+		"STACK":              "line 1\nline 2",
+		"REQUEST_ID":         "42",
+	}, readJournalEntry(t, conn))
+}
+
+func TestJournaldTargetFieldCollisions(t *testing.T) {
+	conn, path := listenJournal(t)
+
+	journaldTarget := blackbox.NewJournaldTarget().
+		SetSocketPath(path).
+		SetIdentifier("myapp")
+	defer journaldTarget.Close(context.Background())
+
+	err := journaldTarget.TryLog("AAA-AAA", blackbox.Error, []any{"Hello"}, blackbox.Ctx{
+		"message":           "from context",
+		"priority":          "7",
+		"syslog.identifier": "other",
+		"code":              blackbox.Ctx{"file": "other.go"},
+	}, func() *blackbox.Source {
+		return &blackbox.Source{File: "main.go", Line: 12, Function: "main.main"}
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"MESSAGE":             "Hello",
+		"PRIORITY":            "3",
+		"SYSLOG_IDENTIFIER":   "myapp",
+		"CODE_FILE":           "main.go",
+		"CODE_LINE":           "12",
+		"CODE_FUNC":           "main.main",
+		"X_MESSAGE":           "from context",
+		"X_PRIORITY":          "7",
+		"X_SYSLOG_IDENTIFIER": "other",
+		"X_CODE_FILE":         "other.go",
+	}, readJournalEntry(t, conn))
+}
+
+func TestJournaldTargetConcurrentChanges(t *testing.T) {
+	conn, path := listenJournal(t)
+
+	journaldTarget := blackbox.NewJournaldTarget().SetSocketPath(path)
+	defer journaldTarget.Close(context.Background())
+
+	// The socket only queues a few datagrams, so entries are read as they are
+	// written.
+	last := make(chan map[string]string, 1)
+	go func() {
+		for {
+			fields := readJournalEntry(t, conn)
+			if len(fields) == 0 || fields["MESSAGE"] == "Last" {
+				last <- fields
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				journaldTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil)
+			}
+		}()
+	}
+
+	for j := 0; j < 100; j++ {
+		journaldTarget.SetIdentifier("app" + strconv.Itoa(j))
+	}
+	wg.Wait()
+
+	journaldTarget.SetIdentifier("myapp")
+	assert.NoError(t, journaldTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Last"}, nil, nil))
+	fields := <-last
+	assert.Equal(t, "Last", fields["MESSAGE"])
+	assert.Equal(t, "myapp", fields["SYSLOG_IDENTIFIER"])
+}
+
+func TestJournaldTargetLevel(t *testing.T) {
+	conn, path := listenJournal(t)
+
+	journaldTarget := blackbox.NewJournaldTarget().SetSocketPath(path).SetLevel(blackbox.Info)
+	defer journaldTarget.Close(context.Background())
+
+	assert.NoError(t, journaldTarget.TryLog("AAA-AAA", blackbox.Debug, []any{"Dropped"}, nil, nil))
+	assert.NoError(t, journaldTarget.TryLog("AAA-AAA", blackbox.Error, []any{"Message"}, nil, nil))
+
+	fields := readJournalEntry(t, conn)
+	assert.Equal(t, "Message", fields["MESSAGE"])
+	assert.Equal(t, "3", fields["PRIORITY"])
+}
+
+func TestJournaldTargetLargeEntry(t *testing.T) {
+	conn, path := listenJournal(t)
+
+	journaldTarget := blackbox.NewJournaldTarget().SetSocketPath(path)
+	defer journaldTarget.Close(context.Background())
+
+	large := strings.Repeat("a", 4*1024*1024)
+	assert.NoError(t, journaldTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Large"}, blackbox.Ctx{"data": large}, nil))
+
+	fields := readJournalEntry(t, conn)
+	assert.Equal(t, "Large", fields["MESSAGE"])
+	assert.Equal(t, large, fields["DATA"])
+}
+
+func TestJournaldTargetMissingSocket(t *testing.T) {
+	journaldTarget := blackbox.NewJournaldTarget().SetSocketPath(filepath.Join(t.TempDir(), "missing.sock"))
+
+	assert.Error(t, journaldTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil))
+}
+
+func TestJournaldTargetReconnects(t *testing.T) {
+	conn, path := listenJournal(t)
+	journaldTarget := blackbox.NewJournaldTarget().SetSocketPath(path)
+
+	assert.NoError(t, journaldTarget.TryLog("AAA-AAA", blackbox.Info, []any{"First"}, nil, nil))
+	assert.Equal(t, "First", readJournalEntry(t, conn)["MESSAGE"])
+
+	conn.Close()
+	assert.NoError(t, os.Remove(path))
+	assert.Error(t, journaldTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Lost"}, nil, nil))
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, journaldTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Second"}, nil, nil))
+	assert.Equal(t, "Second", readJournalEntry(t, conn)["MESSAGE"])
+}
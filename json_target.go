@@ -4,113 +4,433 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
+// ValueFallback decides what a target does with a context value that it can
+// not encode.
+type ValueFallback int
+
+const (
+	// StringifyValue replaces the value with its %+v formatted string
+	StringifyValue ValueFallback = iota
+	// DropValue removes the value from the output
+	DropValue
+)
+
+// JSONEncoder is an Encoder that produces newline separated json output
+// containing log data.
+type JSONEncoder struct {
+	showLoggerID  atomic.Bool
+	showTimestamp atomic.Bool
+	showLevel     atomic.Bool
+	showContext   atomic.Bool
+	useSource     atomic.Bool
+	valueFallback atomic.Int64
+}
+
+var _ FieldEncoder = &JSONEncoder{}
+var _ TimedEncoder = &JSONEncoder{}
+
+// NewJSONEncoder creates a JSONEncoder for use with a StreamTarget
+func NewJSONEncoder() *JSONEncoder {
+	encoder := &JSONEncoder{}
+	encoder.showTimestamp.Store(true)
+	encoder.showLevel.Store(true)
+	encoder.showContext.Store(true)
+	return encoder
+}
+
+// ShowLoggerID will enable or disable logger ID values in the output depending
+// on the boolean value passed.
+func (j *JSONEncoder) ShowLoggerID(b bool) *JSONEncoder {
+	j.showLoggerID.Store(b)
+	return j
+}
+
+// ShowTimestamp will enable or disable timestamps in the output depending on
+// the boolean value passed.
+func (j *JSONEncoder) ShowTimestamp(b bool) *JSONEncoder {
+	j.showTimestamp.Store(b)
+	return j
+}
+
+// ShowLevel will enable or disable level values in the output depending on
+// the boolean value passed.
+func (j *JSONEncoder) ShowLevel(b bool) *JSONEncoder {
+	j.showLevel.Store(b)
+	return j
+}
+
+// ShowContext will enable or disable context key value pairs in the output
+// depending on the boolean value passed.
+func (j *JSONEncoder) ShowContext(b bool) *JSONEncoder {
+	j.showContext.Store(b)
+	return j
+}
+
+// UseSource enables the inclusion of source
+func (j *JSONEncoder) UseSource(b bool) *JSONEncoder {
+	j.useSource.Store(b)
+	return j
+}
+
+// SetValueFallback sets what happens to context values that can not be
+// encoded as json. By default they are replaced with their %+v formatted
+// string.
+func (j *JSONEncoder) SetValueFallback(fallback ValueFallback) *JSONEncoder {
+	j.valueFallback.Store(int64(fallback))
+	return j
+}
+
+// UsesSource reports whether JSONEncoder includes the source of entries.
+func (j *JSONEncoder) UsesSource() bool {
+	return j.useSource.Load()
+}
+
+// Encode returns the entry as a line of json.
+func (j *JSONEncoder) Encode(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) ([]byte, error) {
+	return j.EncodeFields(loggerID, level, values, context, nil, getSource)
+}
+
+// EncodeFields returns the entry as a line of json, with the fields written
+// into the context object after the context values.
+func (j *JSONEncoder) EncodeFields(loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) ([]byte, error) {
+	return j.EncodeAt(time.Now(), loggerID, level, values, context, fields, getSource)
+}
+
+// EncodeAt behaves the same as EncodeFields, but uses the given time for the
+// entry.
+func (j *JSONEncoder) EncodeAt(at time.Time, loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) ([]byte, error) {
+	showContext := j.showContext.Load()
+
+	jsonData := make(map[string]any, 1)
+	if j.showTimestamp.Load() {
+		jsonData["time"] = at.Local().Format(time.RFC3339)
+	}
+	if j.showLevel.Load() {
+		jsonData["level"] = level.String()
+	}
+	strValues := make([]string, 0)
+	for _, value := range values {
+		strValues = append(strValues, fmt.Sprintf("%+v", value))
+	}
+	jsonData["message"] = strings.Join(strValues, " ")
+	if showContext {
+		if len(fields) == 0 {
+			jsonData["context"] = context
+		} else {
+			jsonData["context"] = json.RawMessage(j.appendContext(nil, context, fields))
+		}
+	}
+	if j.showLoggerID.Load() {
+		jsonData["loggerID"] = loggerID
+	}
+	if j.useSource.Load() && getSource != nil {
+		jsonData["source"] = getSource()
+	}
+
+	jsonBytes, err := json.Marshal(jsonData)
+	if err != nil && showContext {
+		jsonData["context"] = encodableContext(context, ValueFallback(j.valueFallback.Load()))
+		jsonBytes, err = json.Marshal(jsonData)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append(jsonBytes, byte('\n')), nil
+}
+
+// appendContext appends a json object holding the context values, in key
+// order, followed by the fields. Values that can not be encoded are handled
+// according to the encoder's value fallback.
+func (j *JSONEncoder) appendContext(buf []byte, context Ctx, fields []Field) []byte {
+	keys := make([]string, 0, len(context))
+	for key := range context {
+		if !hasField(fields, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	buf = append(buf, '{')
+	for _, key := range keys {
+		value, err := json.Marshal(context[key])
+		if err != nil {
+			if ValueFallback(j.valueFallback.Load()) == DropValue {
+				continue
+			}
+			value, _ = json.Marshal(fmt.Sprintf("%+v", context[key]))
+		}
+		buf = appendJSONKey(buf, key)
+		buf = append(buf, value...)
+	}
+	for index, field := range fields {
+		if fieldShadowed(fields, index) {
+			continue
+		}
+		start := len(buf)
+		buf = appendJSONKey(buf, field.Key)
+		var err error
+		if buf, err = appendJSONField(buf, field); err != nil {
+			buf = buf[:start]
+			if ValueFallback(j.valueFallback.Load()) == DropValue {
+				continue
+			}
+			buf = appendJSONKey(buf, field.Key)
+			buf = appendJSONString(buf, field.format())
+		}
+	}
+	return append(buf, '}')
+}
+
 // JSONTarget is a Target that produces newline separated json output containing
-// log data.
+// log data. It is a StreamTarget that writes a JSONEncoder's output to a
+// SplitSink.
 type JSONTarget struct {
-	showLoggerID  bool
-	showTimestamp bool
-	showLevel     bool
-	showContext   bool
-	useSource     bool
-	level         Level
-	outTarget     io.Writer
-	errTarget     io.Writer
+	encoder *JSONEncoder
+	sink    *SplitSink
+	target  *StreamTarget
 }
 
-var _ Target = &JSONTarget{}
+var _ ErrorTarget = &JSONTarget{}
+var _ FieldTarget = &JSONTarget{}
+var _ TimedTarget = &JSONTarget{}
 
 // NewJSONTarget creates a JSONTarget for use with a logger
 func NewJSONTarget(outTarget io.Writer, errTarget io.Writer) *JSONTarget {
+	encoder := NewJSONEncoder()
+	sink := NewSplitSink(outTarget, errTarget)
 	return &JSONTarget{
-		showTimestamp: true,
-		showLevel:     true,
-		showContext:   true,
-		level:         Trace,
-		outTarget:     outTarget,
-		errTarget:     errTarget,
+		encoder: encoder,
+		sink:    sink,
+		target:  NewStreamTarget(encoder, sink),
 	}
 }
 
 // SetLevel sets the minimum log level that JSONTarget will output. Note that
 // this setting is independent of the log level set on the logger itself.
 func (j *JSONTarget) SetLevel(level Level) *JSONTarget {
-	j.level = level
+	j.target.SetLevel(level)
 	return j
 }
 
 // ShowTimestamp will enable or disable timestamps in the output depending on
 // the boolean value passed.
 func (j *JSONTarget) ShowTimestamp(b bool) *JSONTarget {
-	j.showTimestamp = b
+	j.encoder.ShowTimestamp(b)
 	return j
 }
 
 // ShowLevel will enable or disable level values in the output depending on
 // the boolean value passed.
 func (j *JSONTarget) ShowLevel(b bool) *JSONTarget {
-	j.showLevel = b
+	j.encoder.ShowLevel(b)
 	return j
 }
 
 // ShowContext will enable or disable context key value pairs in the output
 // depending on the boolean value passed.
 func (j *JSONTarget) ShowContext(b bool) *JSONTarget {
-	j.showContext = b
+	j.encoder.ShowContext(b)
 	return j
 }
 
 // UseSource enables the inclusion of source
-func (s *JSONTarget) UseSource(b bool) *JSONTarget {
-	s.useSource = b
-	return s
+func (j *JSONTarget) UseSource(b bool) *JSONTarget {
+	j.encoder.UseSource(b)
+	return j
+}
+
+// SetValueFallback sets what happens to context values that can not be
+// encoded as json. By default they are replaced with their %+v formatted
+// string.
+func (j *JSONTarget) SetValueFallback(fallback ValueFallback) *JSONTarget {
+	j.encoder.SetValueFallback(fallback)
+	return j
+}
+
+// SetErrorLevel sets the minimum level of the entries written to errTarget.
+// The default is Warn. Entries below it are written to outTarget.
+func (j *JSONTarget) SetErrorLevel(level Level) *JSONTarget {
+	j.sink.SetErrorLevel(level)
+	return j
+}
+
+// SetRouter sets a function that picks the writer for each entry from its
+// level, in place of outTarget and errTarget. Entries for which the router
+// returns nil are discarded. See RouteByLevel for routing levels to writers
+// with a map.
+func (j *JSONTarget) SetRouter(router func(level Level) io.Writer) *JSONTarget {
+	j.sink.SetRouter(router)
+	return j
+}
+
+// SetRedactor sets a Redactor used to mask secrets in values and context
+// before they are output. Passing nil disables redaction.
+func (j *JSONTarget) SetRedactor(redactor *Redactor) *JSONTarget {
+	j.target.SetRedactor(redactor)
+	return j
+}
+
+// Enabled reports whether JSONTarget accepts entries at the given level.
+func (j *JSONTarget) Enabled(level Level) bool {
+	return j.target.Enabled(level)
+}
+
+// UsesSource reports whether JSONTarget includes the source of entries.
+func (j *JSONTarget) UsesSource() bool {
+	return j.target.UsesSource()
 }
 
 // Log takes a Level and series of values, then outputs them formatted
-// accordingly.
+// accordingly. Errors are written to stderr.
 func (j *JSONTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
-	if level < j.level {
-		return
+	if err := j.TryLog(loggerID, level, values, context, getSource); err != nil {
+		reportTargetError(j, err)
 	}
+}
 
-	jsonData := make(map[string]any, 1)
-	if j.showTimestamp {
-		jsonData["time"] = time.Now().Local().Format(time.RFC3339)
-	}
-	if j.showLevel {
-		jsonData["level"] = level.String()
+// TryLog behaves the same as Log, but returns any error encountered while
+// writing the entry.
+func (j *JSONTarget) TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return j.target.TryLog(loggerID, level, values, context, getSource)
+}
+
+// LogFields behaves the same as TryLog, but also writes the given fields.
+func (j *JSONTarget) LogFields(loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) error {
+	return j.target.LogFields(loggerID, level, values, context, fields, getSource)
+}
+
+// LogAt behaves the same as TryLog, but uses the given time for the entry.
+func (j *JSONTarget) LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return j.target.LogAt(at, loggerID, level, values, context, getSource)
+}
+
+// encodableContext returns a copy of context in which each value that can not
+// be encoded as json has been handled according to fallback. Nested contexts
+// and maps are copied the same way, so only the offending values within them
+// are replaced or dropped.
+func encodableContext(context Ctx, fallback ValueFallback) Ctx {
+	newContext := make(Ctx, len(context))
+	for key, value := range context {
+		value, ok := encodableValue(value, fallback)
+		if !ok {
+			continue
+		}
+		newContext[key] = value
 	}
-	strValues := make([]string, 0)
-	for _, value := range values {
-		strValues = append(strValues, fmt.Sprintf("%+v", value))
+	return newContext
+}
+
+// encodableValue returns value handled according to fallback if it can not be
+// encoded as json. It returns false if the value should be dropped.
+func encodableValue(value any, fallback ValueFallback) (any, bool) {
+	switch value := value.(type) {
+	case Ctx:
+		if value != nil {
+			return encodableContext(value, fallback), true
+		}
+	case map[string]any:
+		if value != nil {
+			return map[string]any(encodableContext(value, fallback)), true
+		}
 	}
-	jsonData["message"] = strings.Join(strValues, " ")
-	if j.showContext {
-		jsonData["context"] = context
+	if _, err := json.Marshal(value); err != nil {
+		if fallback == DropValue {
+			return nil, false
+		}
+		return fmt.Sprintf("%+v", value), true
 	}
-	if j.showLoggerID {
-		jsonData["loggerID"] = loggerID
-	}
-	if j.useSource {
-		jsonData["source"] = getSource()
+	return value, true
+}
+
+func appendJSONKey(buf []byte, key string) []byte {
+	if buf[len(buf)-1] != '{' {
+		buf = append(buf, ',')
 	}
+	buf = appendJSONString(buf, key)
+	return append(buf, ':')
+}
 
-	jsonBytes, err := json.Marshal(jsonData)
+// appendJSONField appends the value of field as json. An error is returned if
+// the value can not be encoded.
+func appendJSONField(buf []byte, field Field) ([]byte, error) {
+	switch field.kind {
+	case fieldString:
+		return appendJSONString(buf, field.str), nil
+	case fieldInt, fieldDuration:
+		return strconv.AppendInt(buf, int64(field.num), 10), nil
+	case fieldUint:
+		return strconv.AppendUint(buf, field.num, 10), nil
+	case fieldFloat:
+		return appendJSONFloat(buf, math.Float64frombits(field.num))
+	case fieldBool:
+		return strconv.AppendBool(buf, field.num == 1), nil
+	case fieldTime:
+		buf = append(buf, '"')
+		buf = field.time().AppendFormat(buf, time.RFC3339Nano)
+		return append(buf, '"'), nil
+	case fieldError:
+		return appendJSONString(buf, field.format()), nil
+	}
+	value, err := json.Marshal(field.value)
 	if err != nil {
-		panic(err)
+		return buf, err
 	}
+	return append(buf, value...), nil
+}
 
-	jsonBytes = append(jsonBytes, byte('\n'))
-
-	if level >= Warn {
-		_, err = j.errTarget.Write(jsonBytes)
-	} else {
-		_, err = j.outTarget.Write(jsonBytes)
+// appendJSONFloat appends f in the same format encoding/json uses.
+func appendJSONFloat(buf []byte, f float64) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return buf, fmt.Errorf("json: unsupported value: %s", strconv.FormatFloat(f, 'g', -1, 64))
 	}
-	if err != nil {
-		panic(err)
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	start := len(buf)
+	buf = strconv.AppendFloat(buf, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9, as encoding/json does.
+		if n := len(buf) - start; n >= 4 && buf[len(buf)-4] == 'e' && buf[len(buf)-3] == '-' && buf[len(buf)-2] == '0' {
+			buf[len(buf)-2] = buf[len(buf)-1]
+			buf = buf[:len(buf)-1]
+		}
+	}
+	return buf, nil
+}
+
+// appendJSONString appends s as a json string, escaped in the same way
+// encoding/json escapes strings.
+func appendJSONString(buf []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	buf = append(buf, '"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf = append(buf, '\\', byte(r))
+		case r == '\n':
+			buf = append(buf, '\\', 'n')
+		case r == '\r':
+			buf = append(buf, '\\', 'r')
+		case r == '\t':
+			buf = append(buf, '\\', 't')
+		case r < 0x20 || r == '<' || r == '>' || r == '&':
+			buf = append(buf, '\\', 'u', '0', '0', hex[r>>4], hex[r&0xf])
+		case r == '\u2028' || r == '\u2029':
+			buf = append(buf, '\\', 'u', '2', '0', '2', hex[r&0xf])
+		default:
+			buf = utf8.AppendRune(buf, r)
+		}
 	}
+	return append(buf, '"')
 }
@@ -3,6 +3,7 @@ package blackbox_test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/RobertWHurst/blackbox"
@@ -97,3 +98,78 @@ func TestJsonTargetShowContext(t *testing.T) {
 	assert.Equal(t, "trace", output.Level)
 	assert.Empty(t, output.Context)
 }
+
+func TestJsonTargetUnencodableValue(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	jsonTarget := blackbox.NewJSONTarget(outBuf, errBuf)
+
+	values := make([]any, 1)
+	values[0] = "Hello Test"
+
+	err := jsonTarget.TryLog("AAA-AAA", blackbox.Trace, values, blackbox.Ctx{"key": "value", "ch": make(chan int)}, nil)
+	assert.NoError(t, err)
+
+	var output JSONOutput
+	assert.NoError(t, json.Unmarshal(outBuf.Bytes(), &output))
+
+	assert.Equal(t, "value", output.Context["key"])
+	assert.Regexp(t, `^0x[0-9a-f]+$`, output.Context["ch"])
+}
+
+func TestJsonTargetSetValueFallback(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	jsonTarget := blackbox.NewJSONTarget(outBuf, errBuf)
+
+	jsonTarget.SetValueFallback(blackbox.DropValue)
+
+	values := make([]any, 1)
+	values[0] = "Hello Test"
+
+	err := jsonTarget.TryLog("AAA-AAA", blackbox.Trace, values, blackbox.Ctx{"key": "value", "ch": make(chan int)}, nil)
+	assert.NoError(t, err)
+
+	var output JSONOutput
+	assert.NoError(t, json.Unmarshal(outBuf.Bytes(), &output))
+
+	assert.Equal(t, blackbox.Ctx{"key": "value"}, output.Context)
+}
+
+func TestJsonTargetNestedUnencodableValue(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	jsonTarget := blackbox.NewJSONTarget(outBuf, errBuf).SetValueFallback(blackbox.DropValue)
+
+	values := make([]any, 1)
+	values[0] = "Hello Test"
+
+	err := jsonTarget.TryLog("AAA-AAA", blackbox.Trace, values, blackbox.Ctx{
+		"request": blackbox.Ctx{"id": "abc", "ch": make(chan int)},
+		"meta":    map[string]any{"count": 1, "fn": func() {}},
+	}, nil)
+	assert.NoError(t, err)
+
+	var output JSONOutput
+	assert.NoError(t, json.Unmarshal(outBuf.Bytes(), &output))
+
+	assert.Equal(t, map[string]any{"id": "abc"}, output.Context["request"])
+	assert.Equal(t, map[string]any{"count": float64(1)}, output.Context["meta"])
+}
+
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("closed pipe")
+}
+
+func TestJsonTargetWriteError(t *testing.T) {
+	jsonTarget := blackbox.NewJSONTarget(errWriter{}, errWriter{})
+
+	values := make([]any, 1)
+	values[0] = "Hello Test"
+
+	err := jsonTarget.TryLog("AAA-AAA", blackbox.Trace, values, blackbox.Ctx{}, nil)
+
+	assert.EqualError(t, err, "closed pipe")
+}
@@ -1,5 +1,7 @@
 package blackbox
 
+import "sync/atomic"
+
 const (
 	// Trace log level
 	Trace Level = iota
@@ -69,3 +71,36 @@ func (l Level) String() string {
 	}
 	return levelStr
 }
+
+// AtomicLevel is a Level that can be read and changed safely while entries
+// are being logged. A logger and its sub loggers share a single AtomicLevel,
+// so changing it affects every one of them. An AtomicLevel may also be shared
+// between loggers with Logger.WithLevel. The zero value is Trace.
+type AtomicLevel struct {
+	level atomic.Int64
+}
+
+var _ Enabler = &AtomicLevel{}
+
+// NewAtomicLevel creates an AtomicLevel set to level.
+func NewAtomicLevel(level Level) *AtomicLevel {
+	atomicLevel := &AtomicLevel{}
+	atomicLevel.SetLevel(level)
+	return atomicLevel
+}
+
+// Level returns the current level.
+func (a *AtomicLevel) Level() Level {
+	return Level(a.level.Load())
+}
+
+// SetLevel changes the current level.
+func (a *AtomicLevel) SetLevel(level Level) {
+	a.level.Store(int64(level))
+}
+
+// Enabled reports whether entries at the given level are at or above the
+// current level.
+func (a *AtomicLevel) Enabled(level Level) bool {
+	return level >= a.Level()
+}
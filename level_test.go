@@ -0,0 +1,22 @@
+package blackbox_test
+
+import (
+	"testing"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomicLevel(t *testing.T) {
+	var zeroLevel blackbox.AtomicLevel
+	assert.Equal(t, blackbox.Trace, zeroLevel.Level())
+
+	level := blackbox.NewAtomicLevel(blackbox.Info)
+	assert.Equal(t, blackbox.Info, level.Level())
+	assert.False(t, level.Enabled(blackbox.Debug))
+	assert.True(t, level.Enabled(blackbox.Info))
+
+	level.SetLevel(blackbox.Error)
+	assert.Equal(t, blackbox.Error, level.Level())
+	assert.False(t, level.Enabled(blackbox.Warn))
+}
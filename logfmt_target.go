@@ -0,0 +1,337 @@
+package blackbox
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// LogfmtEncoder is an Encoder that produces newline separated logfmt output,
+// in the form time=... level=info msg="..." key=value. Nested Ctx values are
+// flattened into dotted keys, so a key id within a request context becomes
+// request.id.
+type LogfmtEncoder struct {
+	showLoggerID  atomic.Bool
+	showTimestamp atomic.Bool
+	showLevel     atomic.Bool
+	showContext   atomic.Bool
+	useSource     atomic.Bool
+}
+
+var _ FieldEncoder = &LogfmtEncoder{}
+var _ TimedEncoder = &LogfmtEncoder{}
+
+// NewLogfmtEncoder creates a LogfmtEncoder for use with a StreamTarget
+func NewLogfmtEncoder() *LogfmtEncoder {
+	encoder := &LogfmtEncoder{}
+	encoder.showTimestamp.Store(true)
+	encoder.showLevel.Store(true)
+	encoder.showContext.Store(true)
+	return encoder
+}
+
+// ShowLoggerID will enable or disable logger ID values in the output depending
+// on the boolean value passed.
+func (l *LogfmtEncoder) ShowLoggerID(b bool) *LogfmtEncoder {
+	l.showLoggerID.Store(b)
+	return l
+}
+
+// ShowTimestamp will enable or disable timestamps in the output depending on
+// the boolean value passed.
+func (l *LogfmtEncoder) ShowTimestamp(b bool) *LogfmtEncoder {
+	l.showTimestamp.Store(b)
+	return l
+}
+
+// ShowLevel will enable or disable level values in the output depending on
+// the boolean value passed.
+func (l *LogfmtEncoder) ShowLevel(b bool) *LogfmtEncoder {
+	l.showLevel.Store(b)
+	return l
+}
+
+// ShowContext will enable or disable context key value pairs in the output
+// depending on the boolean value passed.
+func (l *LogfmtEncoder) ShowContext(b bool) *LogfmtEncoder {
+	l.showContext.Store(b)
+	return l
+}
+
+// UseSource enables the inclusion of source
+func (l *LogfmtEncoder) UseSource(b bool) *LogfmtEncoder {
+	l.useSource.Store(b)
+	return l
+}
+
+// UsesSource reports whether LogfmtEncoder includes the source of entries.
+func (l *LogfmtEncoder) UsesSource() bool {
+	return l.useSource.Load()
+}
+
+// Encode returns the entry as a line of logfmt.
+func (l *LogfmtEncoder) Encode(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) ([]byte, error) {
+	return l.EncodeFields(loggerID, level, values, context, nil, getSource)
+}
+
+// EncodeFields returns the entry as a line of logfmt, with the fields sorted
+// in among the context values.
+func (l *LogfmtEncoder) EncodeFields(loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) ([]byte, error) {
+	return l.EncodeAt(time.Now(), loggerID, level, values, context, fields, getSource)
+}
+
+// EncodeAt behaves the same as EncodeFields, but uses the given time for the
+// entry.
+func (l *LogfmtEncoder) EncodeAt(at time.Time, loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) ([]byte, error) {
+	var builder strings.Builder
+	if l.showTimestamp.Load() {
+		writeLogfmtPair(&builder, "time", at.Local().Format(time.RFC3339))
+	}
+	if l.showLevel.Load() {
+		writeLogfmtPair(&builder, "level", level.String())
+	}
+	strValues := make([]string, 0)
+	for _, value := range values {
+		strValues = append(strValues, fmt.Sprintf("%+v", value))
+	}
+	writeLogfmtPair(&builder, "msg", strings.Join(strValues, " "))
+	if l.showLoggerID.Load() {
+		writeLogfmtPair(&builder, "loggerID", loggerID)
+	}
+	if l.showContext.Load() {
+		pairs := make(map[string]string)
+		flattenLogfmtCtx(pairs, "", context)
+		for _, field := range fields {
+			switch typedValue := field.value.(type) {
+			case Ctx:
+				flattenLogfmtCtx(pairs, field.Key+".", typedValue)
+			case map[string]any:
+				flattenLogfmtCtx(pairs, field.Key+".", Ctx(typedValue))
+			default:
+				if field.kind != fieldSkip {
+					pairs[field.Key] = field.format()
+				}
+			}
+		}
+		keys := make([]string, 0, len(pairs))
+		for key := range pairs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeLogfmtPair(&builder, key, pairs[key])
+		}
+	}
+	if l.useSource.Load() && getSource != nil {
+		if source := getSource(); source != nil {
+			writeLogfmtPair(&builder, "source.file", source.File)
+			writeLogfmtPair(&builder, "source.line", strconv.Itoa(source.Line))
+			writeLogfmtPair(&builder, "source.function", source.Function)
+		}
+	}
+	builder.WriteString("\n")
+	return []byte(builder.String()), nil
+}
+
+// LogfmtTarget is a Target that produces newline separated logfmt output. It
+// is a StreamTarget that writes a LogfmtEncoder's output to a SplitSink.
+type LogfmtTarget struct {
+	encoder *LogfmtEncoder
+	sink    *SplitSink
+	target  *StreamTarget
+}
+
+var _ ErrorTarget = &LogfmtTarget{}
+var _ FieldTarget = &LogfmtTarget{}
+var _ TimedTarget = &LogfmtTarget{}
+
+// NewLogfmtTarget creates a LogfmtTarget for use with a logger
+func NewLogfmtTarget(outTarget io.Writer, errTarget io.Writer) *LogfmtTarget {
+	encoder := NewLogfmtEncoder()
+	sink := NewSplitSink(outTarget, errTarget)
+	return &LogfmtTarget{
+		encoder: encoder,
+		sink:    sink,
+		target:  NewStreamTarget(encoder, sink),
+	}
+}
+
+// SetLevel sets the minimum log level that LogfmtTarget will output. Note that
+// this setting is independent of the log level set on the logger itself.
+func (l *LogfmtTarget) SetLevel(level Level) *LogfmtTarget {
+	l.target.SetLevel(level)
+	return l
+}
+
+// ShowLoggerID will enable or disable logger ID values in the output depending
+// on the boolean value passed.
+func (l *LogfmtTarget) ShowLoggerID(b bool) *LogfmtTarget {
+	l.encoder.ShowLoggerID(b)
+	return l
+}
+
+// ShowTimestamp will enable or disable timestamps in the output depending on
+// the boolean value passed.
+func (l *LogfmtTarget) ShowTimestamp(b bool) *LogfmtTarget {
+	l.encoder.ShowTimestamp(b)
+	return l
+}
+
+// ShowLevel will enable or disable level values in the output depending on
+// the boolean value passed.
+func (l *LogfmtTarget) ShowLevel(b bool) *LogfmtTarget {
+	l.encoder.ShowLevel(b)
+	return l
+}
+
+// ShowContext will enable or disable context key value pairs in the output
+// depending on the boolean value passed.
+func (l *LogfmtTarget) ShowContext(b bool) *LogfmtTarget {
+	l.encoder.ShowContext(b)
+	return l
+}
+
+// UseSource enables the inclusion of source
+func (l *LogfmtTarget) UseSource(b bool) *LogfmtTarget {
+	l.encoder.UseSource(b)
+	return l
+}
+
+// SetErrorLevel sets the minimum level of the entries written to errTarget.
+// The default is Warn. Entries below it are written to outTarget.
+func (l *LogfmtTarget) SetErrorLevel(level Level) *LogfmtTarget {
+	l.sink.SetErrorLevel(level)
+	return l
+}
+
+// SetRouter sets a function that picks the writer for each entry from its
+// level, in place of outTarget and errTarget. Entries for which the router
+// returns nil are discarded. See RouteByLevel for routing levels to writers
+// with a map.
+func (l *LogfmtTarget) SetRouter(router func(level Level) io.Writer) *LogfmtTarget {
+	l.sink.SetRouter(router)
+	return l
+}
+
+// SetRedactor sets a Redactor used to mask secrets in values and context
+// before they are output. Passing nil disables redaction.
+func (l *LogfmtTarget) SetRedactor(redactor *Redactor) *LogfmtTarget {
+	l.target.SetRedactor(redactor)
+	return l
+}
+
+// Enabled reports whether LogfmtTarget accepts entries at the given level.
+func (l *LogfmtTarget) Enabled(level Level) bool {
+	return l.target.Enabled(level)
+}
+
+// UsesSource reports whether LogfmtTarget includes the source of entries.
+func (l *LogfmtTarget) UsesSource() bool {
+	return l.target.UsesSource()
+}
+
+// Log takes a Level and series of values, then outputs them formatted
+// accordingly. Errors are written to stderr.
+func (l *LogfmtTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	if err := l.TryLog(loggerID, level, values, context, getSource); err != nil {
+		reportTargetError(l, err)
+	}
+}
+
+// TryLog behaves the same as Log, but returns any error encountered while
+// writing the entry.
+func (l *LogfmtTarget) TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return l.target.TryLog(loggerID, level, values, context, getSource)
+}
+
+// LogFields behaves the same as TryLog, but also writes the given fields.
+func (l *LogfmtTarget) LogFields(loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) error {
+	return l.target.LogFields(loggerID, level, values, context, fields, getSource)
+}
+
+// LogAt behaves the same as TryLog, but uses the given time for the entry.
+func (l *LogfmtTarget) LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return l.target.LogAt(at, loggerID, level, values, context, getSource)
+}
+
+func flattenLogfmtCtx(pairs map[string]string, prefix string, context Ctx) {
+	for key, value := range context {
+		switch typedValue := value.(type) {
+		case Ctx:
+			flattenLogfmtCtx(pairs, prefix+key+".", typedValue)
+		case map[string]any:
+			flattenLogfmtCtx(pairs, prefix+key+".", Ctx(typedValue))
+		default:
+			pairs[prefix+key] = fmt.Sprintf("%+v", value)
+		}
+	}
+}
+
+func writeLogfmtPair(builder *strings.Builder, key string, value string) {
+	if builder.Len() != 0 {
+		builder.WriteByte(' ')
+	}
+	builder.WriteString(logfmtKey(key))
+	builder.WriteByte('=')
+	builder.WriteString(logfmtValue(value))
+}
+
+// logfmtKey replaces the characters that may not appear in a logfmt key, which
+// are spaces, '=', '"' and control characters, with underscores.
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || (r >= 0x7f && r <= 0x9f) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue quotes a value if it is empty or contains a space, '=', '"' or
+// a control character. Within quotes, backslashes and quotes are escaped, as
+// are control characters.
+func logfmtValue(value string) string {
+	needsQuotes := value == ""
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || (r >= 0x7f && r <= 0x9f) {
+			needsQuotes = true
+			break
+		}
+	}
+	if !needsQuotes {
+		return value
+	}
+
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"':
+			builder.WriteString(`\"`)
+		case '\\':
+			builder.WriteString(`\\`)
+		case '\n':
+			builder.WriteString(`\n`)
+		case '\r':
+			builder.WriteString(`\r`)
+		case '\t':
+			builder.WriteString(`\t`)
+		default:
+			if r < ' ' || (r >= 0x7f && r <= 0x9f) {
+				fmt.Fprintf(&builder, `\u%04x`, r)
+			} else {
+				builder.WriteRune(r)
+			}
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
@@ -0,0 +1,84 @@
+package blackbox_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogfmtTarget(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	logfmtTarget := blackbox.NewLogfmtTarget(outBuf, errBuf)
+
+	logfmtTarget.Log("AAA-AAA", blackbox.Info, []any{"Hello", "World"}, blackbox.Ctx{"user": "bob"}, nil)
+
+	assert.Regexp(t, `^time=\S+ level=info msg="Hello World" user=bob\n$`, outBuf.String())
+	assert.Empty(t, errBuf.String())
+}
+
+func TestLogfmtTargetQuoting(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	logfmtTarget := blackbox.NewLogfmtTarget(outBuf, errBuf).ShowTimestamp(false).ShowLevel(false)
+
+	logfmtTarget.Log("AAA-AAA", blackbox.Info, []any{"Message"}, blackbox.Ctx{
+		"empty":     "",
+		"equals":    "a=b",
+		"quote":     `say "hi"`,
+		"backslash": `C:\dir`,
+		"newline":   "line 1\nline 2",
+		"control":   "bell\a",
+		"unicode":   "héllo",
+		"bad key":   1,
+		"err":       errors.New("failed"),
+	}, nil)
+
+	assert.Equal(t, `msg=Message backslash="C:\\dir" bad_key=1 control="bell\u0007" empty="" equals="a=b" err=failed newline="line 1\nline 2" quote="say \"hi\"" unicode=héllo`+"\n", outBuf.String())
+}
+
+func TestLogfmtTargetNestedCtx(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	logfmtTarget := blackbox.NewLogfmtTarget(outBuf, errBuf).ShowTimestamp(false).ShowLevel(false)
+
+	logfmtTarget.Log("AAA-AAA", blackbox.Info, []any{"Message"}, blackbox.Ctx{
+		"request": blackbox.Ctx{
+			"id":      42,
+			"headers": map[string]any{"accept": "text/html"},
+		},
+	}, nil)
+
+	assert.Equal(t, "msg=Message request.headers.accept=text/html request.id=42\n", outBuf.String())
+}
+
+func TestLogfmtTargetOptions(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	logfmtTarget := blackbox.NewLogfmtTarget(outBuf, errBuf).
+		SetLevel(blackbox.Info).
+		ShowTimestamp(false).
+		ShowContext(false).
+		ShowLoggerID(true).
+		UseSource(true)
+
+	getSource := func() *blackbox.Source {
+		return &blackbox.Source{File: "main.go", Line: 12, Function: "main.main"}
+	}
+	logfmtTarget.Log("AAA-AAA", blackbox.Debug, []any{"Dropped"}, nil, getSource)
+	logfmtTarget.Log("AAA-AAA", blackbox.Error, []any{"Failed"}, blackbox.Ctx{"user": "bob"}, getSource)
+
+	assert.Empty(t, outBuf.String())
+	assert.Equal(t, "level=error msg=Failed loggerID=AAA-AAA source.file=main.go source.line=12 source.function=main.main\n", errBuf.String())
+}
+
+func TestLogfmtTargetWriteError(t *testing.T) {
+	logfmtTarget := blackbox.NewLogfmtTarget(&errWriter{}, &errWriter{})
+
+	err := logfmtTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil)
+
+	assert.Error(t, err)
+}
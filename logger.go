@@ -1,27 +1,37 @@
 package blackbox
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"os"
 	"runtime"
+	"time"
 )
 
+// fatalFlushTimeout is how long Fatal and Fatalf will wait for targets to
+// flush before exiting the program.
+const fatalFlushTimeout = 5 * time.Second
+
 // Logger will take log messages and write them to the targets provided
 type Logger struct {
-	id        string
-	level     Level
-	targetSet *targetSet
-	context   Ctx
+	id         string
+	level      *AtomicLevel
+	targetSet  *targetSet
+	context    Ctx
+	fields     []Field
+	scope      *scope
+	extractors *extractorSet
 }
 
 // New creates a new blackbox logger
 func New() *Logger {
 	return &Logger{
-		id:        generateID(),
-		level:     Trace,
-		targetSet: &targetSet{},
-		context:   make(Ctx, 0),
+		id:         generateID(),
+		level:      &AtomicLevel{},
+		targetSet:  &targetSet{},
+		context:    make(Ctx, 0),
+		extractors: &extractorSet{},
 	}
 }
 
@@ -41,7 +51,7 @@ func (l *Logger) Log(level Level, values ...any) *Logger {
 // Logf works the same way as fmt.Printf. Provide a format string and any
 // values you wish.
 func (l *Logger) Logf(level Level, format string, values ...any) *Logger {
-	l.log(level, fmt.Sprintf(format, values...))
+	l.logf(level, format, values)
 	return l
 }
 
@@ -55,7 +65,7 @@ func (l *Logger) Trace(values ...any) *Logger {
 // Tracef is a convenience method for logging values at the trace log level. It
 // behaves the same as Logf.
 func (l *Logger) Tracef(format string, values ...any) *Logger {
-	l.log(Trace, fmt.Sprintf(format, values...))
+	l.logf(Trace, format, values)
 	return l
 }
 
@@ -69,7 +79,7 @@ func (l *Logger) Debug(values ...any) *Logger {
 // Debugf is a convenience method for logging values at the debug log level. It
 // behaves the same as Logf.
 func (l *Logger) Debugf(format string, values ...any) *Logger {
-	l.log(Debug, fmt.Sprintf(format, values...))
+	l.logf(Debug, format, values)
 	return l
 }
 
@@ -83,7 +93,7 @@ func (l *Logger) Verbose(values ...any) *Logger {
 // Verbosef is a convenience method for logging values at the verbose log level. It
 // behaves the same as Logf.
 func (l *Logger) Verbosef(format string, values ...any) *Logger {
-	l.log(Verbose, fmt.Sprintf(format, values...))
+	l.logf(Verbose, format, values)
 	return l
 }
 
@@ -97,7 +107,7 @@ func (l *Logger) Info(values ...any) *Logger {
 // Infof is a convenience method for logging values at the info log level. It
 // behaves the same as Logf.
 func (l *Logger) Infof(format string, values ...any) *Logger {
-	l.log(Info, fmt.Sprintf(format, values...))
+	l.logf(Info, format, values)
 	return l
 }
 
@@ -111,7 +121,7 @@ func (l *Logger) Warn(values ...any) *Logger {
 // Warnf is a convenience method for logging values at the warn log level. It
 // behaves the same as Logf.
 func (l *Logger) Warnf(format string, values ...any) *Logger {
-	l.log(Warn, fmt.Sprintf(format, values...))
+	l.logf(Warn, format, values)
 	return l
 }
 
@@ -125,24 +135,26 @@ func (l *Logger) Error(values ...any) *Logger {
 // Errorf is a convenience method for logging values at the error log level. It
 // behaves the same as Logf.
 func (l *Logger) Errorf(format string, values ...any) *Logger {
-	l.log(Error, fmt.Errorf(format, values...))
+	if enabled, _ := l.enabled(Error); enabled {
+		l.log(Error, fmt.Errorf(format, values...))
+	}
 	return l
 }
 
 // Fatal is a convenience method for logging values at the fatal log level. It
 // behaves the same as Log with the exception that it exits the program with
-// code 1.
+// code 1. Targets are flushed before the program exits.
 func (l *Logger) Fatal(values ...any) {
 	l.log(Fatal, values...)
-	os.Exit(1)
+	l.exit()
 }
 
 // Fatalf is a convenience method for logging values at the fatal log level. It
 // behaves the same as Logf with the exception that it exits the program with
-// code 1.
+// code 1. Targets are flushed before the program exits.
 func (l *Logger) Fatalf(format string, values ...any) {
 	l.log(Fatal, fmt.Sprintf(format, values...))
-	os.Exit(1)
+	l.exit()
 }
 
 // Panic is a convenience method for logging values at the panic log level. It
@@ -159,14 +171,111 @@ func (l *Logger) Panicf(format string, values ...any) {
 	panic(fmt.Sprint(values...))
 }
 
-// SetLevel sets the log level across all targets at once.
+// SetLevel sets the log level across all targets at once. The level is shared
+// with the logger's parent and sub loggers, so it changes for all of them. It
+// is safe to call while entries are being logged.
 func (l *Logger) SetLevel(level Level) {
-	l.level = level
+	l.level.SetLevel(level)
+}
+
+// Level returns the logger's current log level.
+func (l *Logger) Level() Level {
+	return l.level.Level()
+}
+
+// AtomicLevel returns the AtomicLevel shared by the logger, its parent and its
+// sub loggers. It can be handed to code that needs to change the level, such
+// as an admin endpoint, without giving it the logger itself.
+func (l *Logger) AtomicLevel() *AtomicLevel {
+	return l.level
+}
+
+// WithLevel creates a new sub logger that uses level in place of the level
+// shared with this logger. Sub loggers created from it share the new level,
+// so it can be used to control the level of part of a program separately.
+func (l *Logger) WithLevel(level *AtomicLevel) *Logger {
+	logger := *l
+	logger.level = level
+	return &logger
+}
+
+// Enabled reports whether an entry at the given level would be passed to any
+// of the logger's targets. It can be used to avoid building values that are
+// expensive to compute when they would not be logged.
+func (l *Logger) Enabled(level Level) bool {
+	enabled, _ := l.enabled(level)
+	return enabled
+}
+
+// AddTarget adds a target to be written to. The target is shared with all
+// sub loggers, unless the logger was created with WithTargets, in which case
+// it is only added to that logger and its sub loggers. The returned handle can
+// be used to remove the target again.
+func (l *Logger) AddTarget(target Target) *TargetHandle {
+	return l.targetSet.addTarget(target)
+}
+
+// RemoveTarget removes the target from the logger, and reports whether it was
+// found. Targets inherited through WithTargets can only be removed from the
+// logger they were added to. The target is not flushed or closed.
+func (l *Logger) RemoveTarget(target Target) bool {
+	return l.targetSet.removeTarget(target)
+}
+
+// SetTargets replaces all of the logger's targets at once. Entries logged
+// concurrently are passed to either the old or the new targets, never a mix of
+// both. As with RemoveTarget, targets inherited through WithTargets are kept,
+// and the old targets are not flushed or closed.
+func (l *Logger) SetTargets(targets ...Target) {
+	l.targetSet.setTargets(targets)
+}
+
+// WithTargets creates a new sub logger that writes to the targets of this
+// logger as well as the targets given. Targets added to the sub logger, or to
+// its own sub loggers, are not seen by this logger, while targets added to
+// this logger are seen by the sub logger. Processors and the target error
+// handler are inherited in the same way.
+func (l *Logger) WithTargets(targets ...Target) *Logger {
+	logger := *l
+	logger.targetSet = &targetSet{parent: l.targetSet}
+	logger.targetSet.setTargets(targets)
+	return &logger
+}
+
+// AddProcessor adds a processor that is run on every entry before it is
+// passed to the targets. Processors are shared with all sub loggers, and run
+// in the order they were added. If a processor drops an entry, no target will
+// receive it.
+func (l *Logger) AddProcessor(processor Processor) {
+	l.targetSet.addProcessor(processor)
 }
 
-// AddTarget adds a io.Writer to be written to
-func (l *Logger) AddTarget(target Target) {
-	l.targetSet.addTarget(target)
+// OnTargetError sets a handler that is called whenever a target fails to log
+// an entry, either by returning an error from TryLog or by panicking. By
+// default these errors are written to stderr. The handler is shared with all
+// sub loggers, and is called synchronously, so it must not log to the same
+// logger. Errors from a target wrapped in an AsyncTarget are passed to the
+// handler from the AsyncTarget's background goroutine.
+func (l *Logger) OnTargetError(handler func(Target, error)) {
+	l.targetSet.setErrorHandler(handler)
+}
+
+// Flush flushes every target that implements Flusher, giving up once ctx is
+// done. Any errors returned by the targets are joined together and returned.
+// For a logger created with WithTargets, the targets inherited from its parent
+// are flushed as well.
+func (l *Logger) Flush(ctx context.Context) error {
+	return l.targetSet.flush(ctx)
+}
+
+// Close closes every target that implements Closer, and flushes every target
+// that only implements Flusher, giving up once ctx is done. Close should be
+// called before the program exits so buffered entries are not lost. Any errors
+// returned by the targets are joined together and returned. For a logger
+// created with WithTargets, the targets inherited from its parent are only
+// flushed, as the parent is still using them.
+func (l *Logger) Close(ctx context.Context) error {
+	return l.targetSet.close(ctx)
 }
 
 // WithCtx takes a context, merging it with the current one, and creates a new
@@ -174,13 +283,25 @@ func (l *Logger) AddTarget(target Target) {
 // target set as the one WithCtx is called upon.
 func (l *Logger) WithCtx(context Ctx) *Logger {
 	return &Logger{
-		id:        l.id,
-		level:     l.level,
-		context:   l.context.Extend(context),
-		targetSet: l.targetSet,
+		id:         l.id,
+		level:      l.level,
+		context:    l.context.Extend(context),
+		fields:     l.fields,
+		targetSet:  l.targetSet,
+		scope:      l.scope,
+		extractors: l.extractors,
 	}
 }
 
+// WithFields creates a new sub logger that adds the given fields to every
+// entry. Unlike WithCtx, the logger's context is not copied. Fields take
+// precedence over context values with the same key.
+func (l *Logger) WithFields(fields ...Field) *Logger {
+	logger := *l
+	logger.fields = append(l.fields[:len(l.fields):len(l.fields)], fields...)
+	return &logger
+}
+
 // GetCtx returns a ctx instance containing a copy of the logger's internal
 // context data.
 func (l *Logger) GetCtx() Ctx {
@@ -191,13 +312,66 @@ func (l *Logger) GetCtx() Ctx {
 	return ctx
 }
 
+// enabled reports whether an entry at level should be built, and whether its
+// source should be captured. Entries are skipped when they are below the
+// logger's level, or no target is enabled for them. Entries at Error and above
+// are always built for a scope, as they cause it to fail.
+func (l *Logger) enabled(level Level) (bool, bool) {
+	if !l.level.Enabled(level) {
+		return false, false
+	}
+	wanted, wantsSource := l.targetSet.wants(level)
+	if !wanted && l.scope != nil && level >= Error {
+		return true, wantsSource
+	}
+	return wanted, wantsSource
+}
+
+func (l *Logger) logf(level Level, format string, values []any) {
+	if enabled, _ := l.enabled(level); enabled {
+		l.log(level, fmt.Sprintf(format, values...))
+	}
+}
+
 func (l *Logger) log(level Level, values ...any) {
-	if level < l.level {
+	enabled, wantsSource := l.enabled(level)
+	if !enabled {
 		return
 	}
-	pcs := make([]uintptr, 64)
-	n := runtime.Callers(2, pcs)
-	l.targetSet.log(l.id, level, values, l.context, pcs[:n])
+
+	var pc []uintptr
+	if wantsSource {
+		pcs := make([]uintptr, 64)
+		pc = pcs[:runtime.Callers(2, pcs)]
+	}
+
+	// values is copied so that the caller's variadic slice does not escape,
+	// which allows it to stay on the stack when the entry is skipped. This copy
+	// is the one allocation made for an enabled entry without source, and must
+	// stay: scopes and targets such as AsyncTarget, RingTarget and TestTarget
+	// keep the values after the call returns.
+	entryValues := append(make([]any, 0, len(values)), values...)
+
+	if l.scope != nil {
+		l.scope.log(scopeEntry{
+			targetSet: l.targetSet,
+			loggerID:  l.id,
+			level:     level,
+			values:    entryValues,
+			context:   l.context,
+			fields:    l.fields,
+			pc:        pc,
+		})
+		return
+	}
+	l.targetSet.log(time.Time{}, l.id, level, entryValues, l.context, l.fields, pc)
+}
+
+func (l *Logger) exit() {
+	ctx, cancel := context.WithTimeout(context.Background(), fatalFlushTimeout)
+	l.Flush(ctx)
+	cancel()
+	os.Exit(1)
 }
 
 func generateID() string {
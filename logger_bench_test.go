@@ -0,0 +1,128 @@
+package blackbox_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+type discardTarget struct{}
+
+func (discardTarget) Log(loggerID string, level blackbox.Level, values []any, context blackbox.Ctx, getSource func() *blackbox.Source) {
+}
+
+func (discardTarget) UsesSource() bool {
+	return false
+}
+
+func TestLoggerAllocations(t *testing.T) {
+	disabledLogger := blackbox.New()
+	disabledLogger.SetLevel(blackbox.Info)
+	disabledLogger.AddTarget(blackbox.NewJSONTarget(io.Discard, io.Discard))
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+		disabledLogger.Debug("Message", 42, true)
+	}))
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+		disabledLogger.Debugf("Message %d", 42)
+	}))
+
+	filteredLogger := blackbox.New()
+	filteredLogger.AddTarget(blackbox.NewJSONTarget(io.Discard, io.Discard).SetLevel(blackbox.Warn))
+	filteredLogger.AddTarget(blackbox.NewPrettyTarget(io.Discard, io.Discard).SetLevel(blackbox.Error))
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+		filteredLogger.Info("Message", 42, true)
+	}))
+
+	// An enabled entry makes a single allocation, the copy of its values,
+	// which targets are free to keep. No source is captured.
+	noSourceLogger := blackbox.New()
+	noSourceLogger.AddTarget(discardTarget{})
+	assert.Equal(t, 1.0, testing.AllocsPerRun(100, func() {
+		noSourceLogger.Info("Message", 42, true)
+	}))
+}
+
+func BenchmarkLoggerDisabledLevel(b *testing.B) {
+	logger := blackbox.New()
+	logger.SetLevel(blackbox.Info)
+	logger.AddTarget(blackbox.NewJSONTarget(io.Discard, io.Discard))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Debug("Message", 42, true)
+	}
+}
+
+func BenchmarkLoggerDisabledLevelf(b *testing.B) {
+	logger := blackbox.New()
+	logger.SetLevel(blackbox.Info)
+	logger.AddTarget(blackbox.NewJSONTarget(io.Discard, io.Discard))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Debugf("Message %d", 42)
+	}
+}
+
+func BenchmarkLoggerFilteredByTargets(b *testing.B) {
+	logger := blackbox.New()
+	logger.AddTarget(blackbox.NewJSONTarget(io.Discard, io.Discard).SetLevel(blackbox.Warn))
+	logger.AddTarget(blackbox.NewPrettyTarget(io.Discard, io.Discard).SetLevel(blackbox.Error))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("Message", 42, true)
+	}
+}
+
+func BenchmarkLoggerWithoutSource(b *testing.B) {
+	logger := blackbox.New()
+	logger.AddTarget(discardTarget{})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("Message", 42, true)
+	}
+}
+
+func BenchmarkLoggerWithSource(b *testing.B) {
+	logger := blackbox.New()
+	logger.AddTarget(blackbox.NewTestTarget())
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("Message", 42, true)
+	}
+}
+
+func BenchmarkLoggerJSONTarget(b *testing.B) {
+	logger := blackbox.New()
+	logger.AddTarget(blackbox.NewJSONTarget(io.Discard, io.Discard))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("Message", 42, true)
+	}
+}
+
+func BenchmarkLoggerCtx(b *testing.B) {
+	logger := blackbox.NewWithCtx(blackbox.Ctx{"service": "api"})
+	logger.AddTarget(blackbox.NewLogfmtTarget(io.Discard, io.Discard))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("Message", blackbox.Ctx{"user": "bob", "count": i})
+	}
+}
+
+func BenchmarkLoggerFields(b *testing.B) {
+	logger := blackbox.New().WithFields(blackbox.String("service", "api"))
+	logger.AddTarget(blackbox.NewLogfmtTarget(io.Discard, io.Discard))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("Message", blackbox.String("user", "bob"), blackbox.Int("count", i))
+	}
+}
@@ -0,0 +1,180 @@
+package blackbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type loggerContextKey struct{}
+
+// ContextExtractor pulls values out of a context.Context so they can be added
+// to the Ctx of entries logged with one of the logger's Ctx methods, such as
+// InfoCtx. It should return nil if the context holds nothing of interest.
+type ContextExtractor func(ctx context.Context) Ctx
+
+type extractorSet struct {
+	extractors     []ContextExtractor
+	extractorsLock sync.Mutex
+}
+
+// IntoContext returns a copy of ctx carrying the given logger. The logger can
+// be retrieved later with FromContext.
+func IntoContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx. If ctx does not carry a
+// logger, a new logger without any targets is returned, so the result is
+// always safe to log with.
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*Logger); ok {
+		return logger
+	}
+	return New()
+}
+
+// ContextValueExtractor creates a ContextExtractor that adds the value stored
+// in a context.Context under key to the Ctx as ctxKey.
+func ContextValueExtractor(key any, ctxKey string) ContextExtractor {
+	return func(ctx context.Context) Ctx {
+		value := ctx.Value(key)
+		if value == nil {
+			return nil
+		}
+		return Ctx{ctxKey: value}
+	}
+}
+
+// DeadlineExtractor creates a ContextExtractor that adds the deadline of a
+// context.Context, if it has one, to the Ctx as ctxKey.
+func DeadlineExtractor(ctxKey string) ContextExtractor {
+	return func(ctx context.Context) Ctx {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return nil
+		}
+		return Ctx{ctxKey: deadline}
+	}
+}
+
+// AddContextExtractor registers an extractor that will be run against the
+// context.Context passed to the logger's Ctx methods. Extractors are shared
+// with all sub loggers, and run in the order they were added.
+func (l *Logger) AddContextExtractor(extractor ContextExtractor) {
+	l.extractors.addExtractor(extractor)
+}
+
+// LogCtx behaves the same as Log, but also adds the values pulled out of ctx
+// by the logger's extractors to the entry's context.
+func (l *Logger) LogCtx(ctx context.Context, level Level, values ...any) *Logger {
+	l.logCtx(ctx, level, values...)
+	return l
+}
+
+// TraceCtx is a convenience method for logging values at the trace log level.
+// It behaves the same as LogCtx.
+func (l *Logger) TraceCtx(ctx context.Context, values ...any) *Logger {
+	l.logCtx(ctx, Trace, values...)
+	return l
+}
+
+// DebugCtx is a convenience method for logging values at the debug log level.
+// It behaves the same as LogCtx.
+func (l *Logger) DebugCtx(ctx context.Context, values ...any) *Logger {
+	l.logCtx(ctx, Debug, values...)
+	return l
+}
+
+// VerboseCtx is a convenience method for logging values at the verbose log
+// level. It behaves the same as LogCtx.
+func (l *Logger) VerboseCtx(ctx context.Context, values ...any) *Logger {
+	l.logCtx(ctx, Verbose, values...)
+	return l
+}
+
+// InfoCtx is a convenience method for logging values at the info log level.
+// It behaves the same as LogCtx.
+func (l *Logger) InfoCtx(ctx context.Context, values ...any) *Logger {
+	l.logCtx(ctx, Info, values...)
+	return l
+}
+
+// WarnCtx is a convenience method for logging values at the warn log level.
+// It behaves the same as LogCtx.
+func (l *Logger) WarnCtx(ctx context.Context, values ...any) *Logger {
+	l.logCtx(ctx, Warn, values...)
+	return l
+}
+
+// ErrorCtx is a convenience method for logging values at the error log level.
+// It behaves the same as LogCtx.
+func (l *Logger) ErrorCtx(ctx context.Context, values ...any) *Logger {
+	l.logCtx(ctx, Error, values...)
+	return l
+}
+
+// FatalCtx is a convenience method for logging values at the fatal log level.
+// It behaves the same as LogCtx with the exception that it exits the program
+// with code 1. Targets are flushed before the program exits.
+func (l *Logger) FatalCtx(ctx context.Context, values ...any) {
+	l.logCtx(ctx, Fatal, values...)
+	l.exit()
+}
+
+// PanicCtx is a convenience method for logging values at the panic log level.
+// It behaves the same as LogCtx.
+func (l *Logger) PanicCtx(ctx context.Context, values ...any) {
+	l.logCtx(ctx, Panic, values...)
+	panic(fmt.Sprint(values...))
+}
+
+func (l *Logger) logCtx(ctx context.Context, level Level, values ...any) {
+	if enabled, _ := l.enabled(level); enabled {
+		l.withExtractedCtx(ctx).log(level, values...)
+	}
+}
+
+// withExtractedCtx returns a shallow copy of the logger with the values pulled
+// out of ctx added to its context. If nothing is extracted the logger itself
+// is returned.
+func (l *Logger) withExtractedCtx(ctx context.Context) *Logger {
+	extracted := l.extractors.extract(ctx)
+	if len(extracted) == 0 {
+		return l
+	}
+	logger := *l
+	logger.context = l.context.Extend(extracted)
+	return &logger
+}
+
+func (e *extractorSet) extract(ctx context.Context) Ctx {
+	if ctx == nil {
+		return nil
+	}
+
+	e.extractorsLock.Lock()
+	extractors := e.extractors
+	e.extractorsLock.Unlock()
+
+	var extracted Ctx
+	for _, extractor := range extractors {
+		extractorCtx := extractor(ctx)
+		if len(extractorCtx) == 0 {
+			continue
+		}
+		if extracted == nil {
+			extracted = make(Ctx, len(extractorCtx))
+		}
+		for key, value := range extractorCtx {
+			extracted[key] = value
+		}
+	}
+	return extracted
+}
+
+func (e *extractorSet) addExtractor(extractor ContextExtractor) {
+	e.extractorsLock.Lock()
+	e.extractors = append(e.extractors, extractor)
+	e.extractorsLock.Unlock()
+}
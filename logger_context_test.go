@@ -0,0 +1,69 @@
+package blackbox_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+type requestIDKey struct{}
+
+func TestIntoAndFromContext(t *testing.T) {
+	logger := blackbox.New()
+	ctx := blackbox.IntoContext(context.Background(), logger)
+
+	assert.Same(t, logger, blackbox.FromContext(ctx))
+	assert.NotNil(t, blackbox.FromContext(context.Background()))
+}
+
+func TestLoggerInfoCtxExtractsValues(t *testing.T) {
+	logger := blackbox.NewWithCtx(blackbox.Ctx{"service": "api"})
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+	logger.AddContextExtractor(blackbox.ContextValueExtractor(requestIDKey{}, "requestID"))
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "abc123")
+	logger.WithCtx(blackbox.Ctx{"component": "router"}).InfoCtx(ctx, "Message", blackbox.Ctx{"key": "value"})
+
+	logged, ok := testTarget.LastLogged()
+
+	assert.Equal(t, true, ok)
+	assert.Equal(t, blackbox.Info, logged.Level)
+	assert.Equal(t, "Message", logged.Values[0])
+	assert.Equal(t, blackbox.Ctx{
+		"service":   "api",
+		"component": "router",
+		"requestID": "abc123",
+		"key":       "value",
+	}, logged.Context)
+	assert.Contains(t, logged.Source.Function, "TestLoggerInfoCtxExtractsValues")
+}
+
+func TestLoggerLogCtxWithoutValues(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+	logger.AddContextExtractor(blackbox.ContextValueExtractor(requestIDKey{}, "requestID"))
+
+	logger.LogCtx(context.Background(), blackbox.Warn, "Message")
+
+	logged, ok := testTarget.LastLogged()
+
+	assert.Equal(t, true, ok)
+	assert.Equal(t, blackbox.Warn, logged.Level)
+	assert.Empty(t, logged.Context)
+}
+
+func TestDeadlineExtractor(t *testing.T) {
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	extracted := blackbox.DeadlineExtractor("deadline")(ctx)
+
+	assert.Equal(t, blackbox.Ctx{"deadline": deadline}, extracted)
+	assert.Nil(t, blackbox.DeadlineExtractor("deadline")(context.Background()))
+}
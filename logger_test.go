@@ -1,6 +1,11 @@
 package blackbox_test
 
 import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/RobertWHurst/blackbox"
@@ -89,6 +94,43 @@ func TestLoggerInlineCtx(t *testing.T) {
 	assert.Equal(t, blackbox.Ctx{"key": "value"}, logged.Context)
 }
 
+func TestLoggerKeyValuePairs(t *testing.T) {
+	logger := blackbox.NewWithCtx(blackbox.Ctx{"service": "api"})
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	values := []any{"Saved", "id", 7, blackbox.Ctx{"id": 8}, "bytes", 42, "skipped", nil}
+	logger.Info(values...)
+
+	logged, ok := testTarget.LastLogged()
+
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []any{"Saved"}, logged.Values)
+	assert.Equal(t, blackbox.Ctx{"service": "api", "id": 8, "bytes": 42}, logged.Context)
+	assert.Equal(t, []any{"Saved", "id", 7, blackbox.Ctx{"id": 8}, "bytes", 42, "skipped", nil}, values)
+}
+
+func TestLoggerKeyValuePairsKeepMessageValues(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	logger.Info("Hello", "World")
+	logged, _ := testTarget.LastLogged()
+	assert.Equal(t, []any{"Hello", "World"}, logged.Values)
+	assert.Empty(t, logged.Context)
+
+	logger.Info("Message", 42, true, "key", "value", "trailing")
+	logged, _ = testTarget.LastLogged()
+	assert.Equal(t, []any{"Message", 42, true, "trailing"}, logged.Values)
+	assert.Equal(t, blackbox.Ctx{"key": "value"}, logged.Context)
+
+	logger.Info(blackbox.Ctx{"key": "value"}, "Message", "label", blackbox.String("user", "bob"))
+	logged, _ = testTarget.LastLogged()
+	assert.Equal(t, []any{"Message", "label"}, logged.Values)
+	assert.Equal(t, blackbox.Ctx{"key": "value", "user": "bob"}, logged.Context)
+}
+
 func TestLoggerGetSource(t *testing.T) {
 	logger := blackbox.New()
 	testTarget := blackbox.NewTestTarget()
@@ -103,3 +145,373 @@ func TestLoggerGetSource(t *testing.T) {
 	assert.Contains(t, logged.Source.File, "logger_test.go")
 	assert.Greater(t, logged.Source.Line, 5)
 }
+
+type lifecycleTarget struct {
+	flushed  int
+	closed   int
+	closeErr error
+}
+
+func (l *lifecycleTarget) Log(loggerID string, level blackbox.Level, values []any, context blackbox.Ctx, getSource func() *blackbox.Source) {
+}
+
+func (l *lifecycleTarget) Flush(ctx context.Context) error {
+	l.flushed++
+	return nil
+}
+
+func (l *lifecycleTarget) Close(ctx context.Context) error {
+	l.closed++
+	return l.closeErr
+}
+
+func TestLoggerFlush(t *testing.T) {
+	logger := blackbox.New()
+	target := &lifecycleTarget{}
+	logger.AddTarget(target)
+	logger.AddTarget(blackbox.NewTestTarget())
+
+	assert.NoError(t, logger.WithCtx(blackbox.Ctx{"key": "value"}).Flush(context.Background()))
+	assert.Equal(t, 1, target.flushed)
+	assert.Equal(t, 0, target.closed)
+}
+
+func TestLoggerClose(t *testing.T) {
+	logger := blackbox.New()
+	closeErr := errors.New("close failed")
+	target := &lifecycleTarget{closeErr: closeErr}
+	logger.AddTarget(target)
+
+	assert.ErrorIs(t, logger.Close(context.Background()), closeErr)
+	assert.Equal(t, 0, target.flushed)
+	assert.Equal(t, 1, target.closed)
+}
+
+func TestLoggerCloseDeadline(t *testing.T) {
+	logger := blackbox.New()
+	target := &lifecycleTarget{}
+	logger.AddTarget(target)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, logger.Close(ctx), context.Canceled)
+	assert.Equal(t, 0, target.closed)
+}
+
+func TestLoggerCloseAsyncTarget(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(blackbox.NewAsyncTarget(testTarget, 10))
+
+	logger.Info("Message")
+
+	assert.NoError(t, logger.Close(context.Background()))
+
+	logged, ok := testTarget.LastLogged()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Message", logged.Values[0])
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+type panickingTarget struct{}
+
+func (panickingTarget) Log(loggerID string, level blackbox.Level, values []any, context blackbox.Ctx, getSource func() *blackbox.Source) {
+	panic("target failure")
+}
+
+func TestLoggerOnTargetError(t *testing.T) {
+	logger := blackbox.New()
+	jsonTarget := blackbox.NewJSONTarget(failingWriter{}, failingWriter{})
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(jsonTarget)
+	logger.AddTarget(panickingTarget{})
+	logger.AddTarget(testTarget)
+
+	var failedTargets []blackbox.Target
+	var errs []error
+	logger.OnTargetError(func(target blackbox.Target, err error) {
+		failedTargets = append(failedTargets, target)
+		errs = append(errs, err)
+	})
+
+	logger.Info("Message")
+
+	assert.Equal(t, []blackbox.Target{jsonTarget, panickingTarget{}}, failedTargets)
+	assert.EqualError(t, errs[0], "write failed")
+	assert.EqualError(t, errs[1], "blackbox: target panicked: target failure")
+
+	_, ok := testTarget.LastLogged()
+	assert.Equal(t, true, ok)
+}
+
+func TestLoggerRemoveTarget(t *testing.T) {
+	logger := blackbox.New()
+	firstTarget := blackbox.NewTestTarget()
+	secondTarget := blackbox.NewTestTarget()
+	logger.AddTarget(firstTarget)
+	logger.AddTarget(secondTarget)
+
+	assert.True(t, logger.RemoveTarget(firstTarget))
+	assert.False(t, logger.RemoveTarget(firstTarget))
+	assert.False(t, logger.RemoveTarget(blackbox.NewTestTarget()))
+
+	logger.Info("Message")
+
+	assert.Empty(t, firstTarget.AllLogged())
+	assert.Equal(t, 1, len(secondTarget.AllLogged()))
+}
+
+type funcTarget func(level blackbox.Level)
+
+func (f funcTarget) Log(loggerID string, level blackbox.Level, values []any, context blackbox.Ctx, getSource func() *blackbox.Source) {
+	f(level)
+}
+
+func TestLoggerRemoveUncomparableTarget(t *testing.T) {
+	logger := blackbox.New()
+	target := funcTarget(func(level blackbox.Level) {})
+	logger.AddTarget(target)
+
+	assert.NotPanics(t, func() {
+		assert.False(t, logger.RemoveTarget(target))
+	})
+}
+
+func TestLoggerTargetHandleDetach(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	firstHandle := logger.AddTarget(testTarget)
+	secondHandle := logger.AddTarget(testTarget)
+
+	assert.Same(t, testTarget, firstHandle.Target())
+
+	logger.Info("Twice")
+	firstHandle.Detach()
+	firstHandle.Detach()
+	logger.Info("Once")
+	secondHandle.Detach()
+	logger.Info("Never")
+
+	logged := testTarget.AllLogged()
+	assert.Equal(t, 3, len(logged))
+	assert.Equal(t, "Once", logged[2].Values[0])
+}
+
+func TestLoggerSetTargets(t *testing.T) {
+	logger := blackbox.New()
+	oldTarget := blackbox.NewTestTarget()
+	handle := logger.AddTarget(oldTarget)
+
+	firstTarget := blackbox.NewTestTarget()
+	secondTarget := blackbox.NewTestTarget()
+	logger.SetTargets(firstTarget, secondTarget)
+	handle.Detach()
+
+	logger.Info("Message")
+
+	assert.Empty(t, oldTarget.AllLogged())
+	assert.Equal(t, 1, len(firstTarget.AllLogged()))
+	assert.Equal(t, 1, len(secondTarget.AllLogged()))
+}
+
+func TestLoggerWithTargets(t *testing.T) {
+	logger := blackbox.New()
+	parentTarget := blackbox.NewTestTarget()
+	logger.AddTarget(parentTarget)
+
+	childTarget := blackbox.NewTestTarget()
+	childLogger := logger.WithTargets(childTarget).WithCtx(blackbox.Ctx{"key": "value"})
+	lateTarget := blackbox.NewTestTarget()
+	logger.AddTarget(lateTarget)
+
+	childLogger.Info("Child")
+	logger.Info("Parent")
+
+	assert.Equal(t, 2, len(parentTarget.AllLogged()))
+	assert.Equal(t, 2, len(lateTarget.AllLogged()))
+	assert.Equal(t, 1, len(childTarget.AllLogged()))
+	logged, _ := childTarget.LastLogged()
+	assert.Equal(t, "Child", logged.Values[0])
+	assert.Equal(t, blackbox.Ctx{"key": "value"}, logged.Context)
+
+	childLogger.SetTargets()
+	assert.False(t, childLogger.RemoveTarget(parentTarget))
+	childLogger.Info("Inherited")
+
+	assert.Equal(t, 1, len(childTarget.AllLogged()))
+	logged, _ = parentTarget.LastLogged()
+	assert.Equal(t, "Inherited", logged.Values[0])
+}
+
+func TestLoggerWithTargetsFlushAndClose(t *testing.T) {
+	logger := blackbox.New()
+	parentTarget := &lifecycleTarget{}
+	logger.AddTarget(parentTarget)
+
+	childTarget := &lifecycleTarget{}
+	childLogger := logger.WithTargets(childTarget)
+
+	assert.NoError(t, childLogger.Flush(context.Background()))
+	assert.Equal(t, 1, parentTarget.flushed)
+	assert.Equal(t, 1, childTarget.flushed)
+
+	assert.NoError(t, childLogger.Close(context.Background()))
+	assert.Equal(t, 2, parentTarget.flushed)
+	assert.Equal(t, 0, parentTarget.closed)
+	assert.Equal(t, 1, childTarget.closed)
+}
+
+func TestLoggerWithTargetsInheritsProcessors(t *testing.T) {
+	logger := blackbox.New()
+	logger.AddProcessor(blackbox.ProcessorFunc(func(entry *blackbox.Entry) bool {
+		entry.Values = append(entry.Values, "parent")
+		return true
+	}))
+
+	childTarget := blackbox.NewTestTarget()
+	childLogger := logger.WithTargets(childTarget)
+	childLogger.AddProcessor(blackbox.ProcessorFunc(func(entry *blackbox.Entry) bool {
+		entry.Values = append(entry.Values, "child")
+		return true
+	}))
+
+	var failedTargets []blackbox.Target
+	logger.OnTargetError(func(target blackbox.Target, err error) {
+		failedTargets = append(failedTargets, target)
+	})
+	childLogger.AddTarget(panickingTarget{})
+
+	childLogger.Info("Message")
+	logger.Info("Message")
+
+	logged, _ := childTarget.LastLogged()
+	assert.Equal(t, []any{"Message", "parent", "child"}, logged.Values)
+	assert.Equal(t, []blackbox.Target{panickingTarget{}}, failedTargets)
+}
+
+func TestLoggerEnabled(t *testing.T) {
+	logger := blackbox.New()
+	logger.SetLevel(blackbox.Debug)
+	logger.AddTarget(blackbox.NewJSONTarget(io.Discard, io.Discard).SetLevel(blackbox.Info))
+
+	assert.False(t, logger.Enabled(blackbox.Trace))
+	assert.False(t, logger.Enabled(blackbox.Debug))
+	assert.True(t, logger.Enabled(blackbox.Info))
+
+	logger.AddTarget(blackbox.NewTestTarget())
+
+	assert.False(t, logger.Enabled(blackbox.Trace))
+	assert.True(t, logger.Enabled(blackbox.Debug))
+}
+
+func TestLoggerSharedLevel(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	subLogger := logger.WithCtx(blackbox.Ctx{"key": "value"})
+	scope := logger.Scope(blackbox.Trace)
+	separateLogger := logger.WithLevel(blackbox.NewAtomicLevel(blackbox.Trace))
+
+	logger.SetLevel(blackbox.Warn)
+
+	assert.Equal(t, blackbox.Warn, subLogger.Level())
+	assert.Equal(t, blackbox.Warn, scope.Level())
+	assert.Equal(t, blackbox.Trace, separateLogger.Level())
+	assert.Same(t, logger.AtomicLevel(), subLogger.AtomicLevel())
+
+	subLogger.Info("Dropped")
+	separateLogger.WithCtx(blackbox.Ctx{"key": "value"}).Info("Logged")
+
+	assert.Equal(t, 1, len(testTarget.AllLogged()))
+	logged, _ := testTarget.LastLogged()
+	assert.Equal(t, "Logged", logged.Values[0])
+}
+
+func TestLoggerConcurrentChanges(t *testing.T) {
+	logger := blackbox.New()
+	jsonTarget := blackbox.NewJSONTarget(io.Discard, io.Discard)
+	prettyTarget := blackbox.NewPrettyTarget(io.Discard, io.Discard)
+	logger.AddTarget(jsonTarget)
+	logger.AddTarget(prettyTarget)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			subLogger := logger.WithCtx(blackbox.Ctx{"key": "value"})
+			for j := 0; j < 100; j++ {
+				subLogger.Info("Message", "count", j)
+			}
+		}()
+	}
+
+	testTarget := blackbox.NewTestTarget()
+	for j := 0; j < 100; j++ {
+		logger.SetLevel(blackbox.Level(j % 3))
+		jsonTarget.SetLevel(blackbox.Level(j % 4)).ShowTimestamp(j%2 == 0)
+		prettyTarget.UseColor(j%2 == 0).SelectContext("key")
+		if j == 50 {
+			logger.AddTarget(testTarget)
+			logger.AddProcessor(blackbox.ProcessorFunc(func(entry *blackbox.Entry) bool {
+				return true
+			}))
+		}
+	}
+	wg.Wait()
+
+	logger.SetLevel(blackbox.Trace)
+	logger.Info("Last")
+	logged, ok := testTarget.LastLogged()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Last", logged.Values[0])
+}
+
+func TestLoggerSkipsDisabledEntries(t *testing.T) {
+	logger := blackbox.New()
+	logger.SetLevel(blackbox.Info)
+	logger.AddTarget(blackbox.NewJSONTarget(io.Discard, io.Discard).SetLevel(blackbox.Warn))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		logger.Debug("Message", 42, true)
+		logger.Debugf("Message %d", 42)
+		logger.Info("Message", 42, true)
+		logger.Infof("Message %d", 42)
+	})
+
+	assert.Equal(t, float64(0), allocs)
+}
+
+type sourceTarget struct {
+	usesSource bool
+	source     *blackbox.Source
+}
+
+func (s *sourceTarget) Log(loggerID string, level blackbox.Level, values []any, context blackbox.Ctx, getSource func() *blackbox.Source) {
+	s.source = getSource()
+}
+
+func (s *sourceTarget) UsesSource() bool {
+	return s.usesSource
+}
+
+func TestLoggerSkipsUnusedSource(t *testing.T) {
+	logger := blackbox.New()
+	target := &sourceTarget{}
+	logger.AddTarget(target)
+
+	logger.Info("Message")
+	assert.Nil(t, target.source)
+
+	target.usesSource = true
+	logger.Info("Message")
+	assert.NotNil(t, target.source)
+	assert.True(t, strings.HasSuffix(target.source.Function, ".TestLoggerSkipsUnusedSource"))
+}
@@ -0,0 +1,252 @@
+package blackbox
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+const otlpScopeName = "github.com/RobertWHurst/blackbox"
+
+// OTLPEncoder is an HTTPEncoder that encodes entries as an OTLP
+// ExportLogsServiceRequest protobuf message. It is used by the targets created
+// with NewOTLPTarget.
+//
+// Each entry becomes a LogRecord. Levels are mapped to severity numbers as
+// follows, with the severity text set to the upper case level name, such as
+// VERBOSE:
+//
+//	Trace   -> 1  (TRACE)
+//	Debug   -> 5  (DEBUG)
+//	Verbose -> 8  (DEBUG4)
+//	Info    -> 9  (INFO)
+//	Warn    -> 13 (WARN)
+//	Error   -> 17 (ERROR)
+//	Fatal   -> 21 (FATAL)
+//	Panic   -> 24 (FATAL4)
+//
+// Context values become attributes, and the source of an entry becomes the
+// code.file.path, code.line.number and code.function.name attributes. Trace
+// and span IDs found in the context, as hex strings or byte slices, fill the
+// trace fields of the LogRecord. They are only read from the entry's Ctx, so
+// to correlate entries with the active span of a context.Context, add a
+// ContextExtractor that copies its IDs into the Ctx and log with the Ctx
+// methods, such as InfoCtx.
+type OTLPEncoder struct {
+	resourceAttributes Ctx
+	traceIDKey         string
+	spanIDKey          string
+}
+
+var _ HTTPEncoder = &OTLPEncoder{}
+
+// NewOTLPEncoder creates an OTLPEncoder with a service.name resource
+// attribute set to the name of the running program. Trace and span IDs are
+// read from the trace_id and span_id context keys.
+func NewOTLPEncoder() *OTLPEncoder {
+	return &OTLPEncoder{
+		resourceAttributes: Ctx{"service.name": filepath.Base(os.Args[0])},
+		traceIDKey:         "trace_id",
+		spanIDKey:          "span_id",
+	}
+}
+
+// SetResourceAttribute sets an attribute of the resource the entries are
+// reported under, such as service.name or deployment.environment.
+func (o *OTLPEncoder) SetResourceAttribute(key string, value any) *OTLPEncoder {
+	o.resourceAttributes[key] = value
+	return o
+}
+
+// SetTraceContextKeys sets the context keys trace and span IDs are read from.
+func (o *OTLPEncoder) SetTraceContextKeys(traceIDKey string, spanIDKey string) *OTLPEncoder {
+	o.traceIDKey = traceIDKey
+	o.spanIDKey = spanIDKey
+	return o
+}
+
+// ContentType returns application/x-protobuf
+func (o *OTLPEncoder) ContentType() string {
+	return "application/x-protobuf"
+}
+
+// Encode returns an ExportLogsServiceRequest holding the entries
+func (o *OTLPEncoder) Encode(entries []HTTPEntry) ([]byte, error) {
+	// InstrumentationScope: name = 1
+	scope := protoAppendString(nil, 1, otlpScopeName)
+
+	// ScopeLogs: scope = 1, log_records = 2
+	scopeLogs := protoAppendBytes(nil, 1, scope)
+	for _, entry := range entries {
+		scopeLogs = protoAppendBytes(scopeLogs, 2, o.encodeLogRecord(entry))
+	}
+
+	// Resource: attributes = 1
+	resource := otlpAppendAttributes(nil, 1, o.resourceAttributes)
+
+	// ResourceLogs: resource = 1, scope_logs = 2
+	resourceLogs := protoAppendBytes(nil, 1, resource)
+	resourceLogs = protoAppendBytes(resourceLogs, 2, scopeLogs)
+
+	// ExportLogsServiceRequest: resource_logs = 1
+	return protoAppendBytes(nil, 1, resourceLogs), nil
+}
+
+func (o *OTLPEncoder) encodeLogRecord(entry HTTPEntry) []byte {
+	severityNumber, severityText := otlpSeverity(entry.Level)
+	timestamp := uint64(entry.Time.UnixNano())
+
+	attributes := entry.Context.Extend(nil)
+	traceID := otlpID(attributes, o.traceIDKey, 16)
+	spanID := otlpID(attributes, o.spanIDKey, 8)
+	if entry.LoggerID != "" {
+		attributes["blackbox.logger_id"] = entry.LoggerID
+	}
+	if entry.Source != nil {
+		attributes["code.file.path"] = entry.Source.File
+		attributes["code.line.number"] = entry.Source.Line
+		attributes["code.function.name"] = entry.Source.Function
+	}
+
+	// LogRecord: time_unix_nano = 1, severity_number = 2, severity_text = 3,
+	// body = 5, attributes = 6, trace_id = 9, span_id = 10,
+	// observed_time_unix_nano = 11
+	record := protoAppendFixed64(nil, 1, timestamp)
+	record = protoAppendVarint(record, 2, uint64(severityNumber))
+	record = protoAppendString(record, 3, severityText)
+	record = protoAppendBytes(record, 5, otlpAnyValue(entry.Message))
+	record = otlpAppendAttributes(record, 6, attributes)
+	if traceID != nil {
+		record = protoAppendBytes(record, 9, traceID)
+	}
+	if spanID != nil {
+		record = protoAppendBytes(record, 10, spanID)
+	}
+	return protoAppendFixed64(record, 11, timestamp)
+}
+
+func otlpSeverity(level Level) (int, string) {
+	switch level {
+	case Trace:
+		return 1, "TRACE"
+	case Debug:
+		return 5, "DEBUG"
+	case Verbose:
+		return 8, "VERBOSE"
+	case Info:
+		return 9, "INFO"
+	case Warn:
+		return 13, "WARN"
+	case Error:
+		return 17, "ERROR"
+	case Fatal:
+		return 21, "FATAL"
+	}
+	return 24, "PANIC"
+}
+
+// otlpID removes and returns the ID of the given length held under key, if
+// there is a valid one.
+func otlpID(context Ctx, key string, length int) []byte {
+	var id []byte
+	switch value := context[key].(type) {
+	case string:
+		decoded, err := hex.DecodeString(value)
+		if err != nil {
+			return nil
+		}
+		id = decoded
+	case []byte:
+		id = value
+	case fmt.Stringer:
+		decoded, err := hex.DecodeString(value.String())
+		if err != nil {
+			return nil
+		}
+		id = decoded
+	default:
+		return nil
+	}
+	if len(id) != length {
+		return nil
+	}
+	delete(context, key)
+	return id
+}
+
+// otlpAppendAttributes appends each key value pair of context as a KeyValue
+// message in the given field, in key order.
+func otlpAppendAttributes(b []byte, field int, context Ctx) []byte {
+	keys := make([]string, 0, len(context))
+	for key := range context {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		// KeyValue: key = 1, value = 2
+		keyValue := protoAppendString(nil, 1, key)
+		keyValue = protoAppendBytes(keyValue, 2, otlpAnyValue(context[key]))
+		b = protoAppendBytes(b, field, keyValue)
+	}
+	return b
+}
+
+// otlpAnyValue encodes value as an AnyValue message, which has the fields
+// string_value = 1, bool_value = 2, int_value = 3, double_value = 4,
+// array_value = 5, kvlist_value = 6 and bytes_value = 7. Unsigned integers
+// too large for int_value are encoded as their decimal string, and values of
+// other types are encoded as their %+v formatted string.
+func otlpAnyValue(value any) []byte {
+	switch typedValue := value.(type) {
+	case nil:
+		return []byte{}
+	case string:
+		return protoAppendString(nil, 1, typedValue)
+	case []byte:
+		return protoAppendBytes(nil, 7, typedValue)
+	case Ctx:
+		// KeyValueList: values = 1
+		return protoAppendBytes(nil, 6, otlpAppendAttributes(nil, 1, typedValue))
+	case map[string]any:
+		return protoAppendBytes(nil, 6, otlpAppendAttributes(nil, 1, typedValue))
+	case error:
+		return protoAppendString(nil, 1, typedValue.Error())
+	case fmt.Stringer:
+		return protoAppendString(nil, 1, typedValue.String())
+	}
+
+	reflectValue := reflect.ValueOf(value)
+	switch reflectValue.Kind() {
+	case reflect.Bool:
+		var b uint64
+		if reflectValue.Bool() {
+			b = 1
+		}
+		return protoAppendVarint(nil, 2, b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return protoAppendVarint(nil, 3, uint64(reflectValue.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if reflectValue.Uint() > math.MaxInt64 {
+			return protoAppendString(nil, 1, strconv.FormatUint(reflectValue.Uint(), 10))
+		}
+		return protoAppendVarint(nil, 3, reflectValue.Uint())
+	case reflect.Float32, reflect.Float64:
+		return protoAppendDouble(nil, 4, reflectValue.Float())
+	case reflect.String:
+		return protoAppendString(nil, 1, reflectValue.String())
+	case reflect.Slice, reflect.Array:
+		// ArrayValue: values = 1
+		var array []byte
+		for i := 0; i < reflectValue.Len(); i++ {
+			array = protoAppendBytes(array, 1, otlpAnyValue(reflectValue.Index(i).Interface()))
+		}
+		return protoAppendBytes(nil, 5, array)
+	}
+	return protoAppendString(nil, 1, fmt.Sprintf("%+v", value))
+}
@@ -0,0 +1,273 @@
+package blackbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLPProtocol is the protocol an OTLP target uses to reach the collector.
+type OTLPProtocol int
+
+const (
+	// OTLPHTTP sends protobuf encoded requests over HTTP to the /v1/logs path
+	// of the endpoint.
+	OTLPHTTP OTLPProtocol = iota
+	// OTLPGRPC sends requests to the gRPC LogsService of the endpoint. gRPC
+	// requires HTTP/2, which the default client only uses over TLS. To use an
+	// unencrypted endpoint, set a client supporting HTTP/2 without TLS with
+	// SetClient.
+	OTLPGRPC
+)
+
+const otlpGRPCExportPath = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+
+// NewOTLPTarget creates an HTTPTarget that exports entries to an OpenTelemetry
+// collector as OTLP LogRecords, encoded with encoder. The endpoint is the base
+// URL of the collector, such as http://localhost:4318 for OTLPHTTP or
+// https://localhost:4317 for OTLPGRPC. If encoder is nil, NewOTLPEncoder is
+// used.
+//
+// Following the batch log record processor of the OpenTelemetry SDKs, batches
+// are sent once they hold 512 entries or are a second old. Failed exports are
+// retried with backoff, and only when the OTLP specification allows it:
+// HTTP status codes 429, 502, 503 and 504, and the gRPC codes CANCELLED,
+// DEADLINE_EXCEEDED, ABORTED, OUT_OF_RANGE, UNAVAILABLE and DATA_LOSS, as well
+// as RESOURCE_EXHAUSTED when the collector provides a retry delay. Delays
+// requested by the collector are honoured. Records rejected by the collector
+// in a partial success response are counted as dropped.
+func NewOTLPTarget(endpoint string, protocol OTLPProtocol, encoder *OTLPEncoder) *HTTPTarget {
+	if encoder == nil {
+		encoder = NewOTLPEncoder()
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	var h *HTTPTarget
+	if protocol == OTLPGRPC {
+		h = NewHTTPTarget(endpoint+otlpGRPCExportPath, encoder)
+		h.sender = func(body []byte) error {
+			return sendOTLPGRPC(h, body)
+		}
+	} else {
+		h = NewHTTPTarget(endpoint+"/v1/logs", encoder)
+		h.sender = func(body []byte) error {
+			return sendOTLPHTTP(h, body)
+		}
+	}
+	return h.SetBatchLimits(512, 0, time.Second)
+}
+
+type otlpHTTPStatusError struct {
+	*httpStatusError
+}
+
+func (e *otlpHTTPStatusError) retryable() bool {
+	switch e.statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func sendOTLPHTTP(h *HTTPTarget, body []byte) error {
+	res, err := h.post(h.url, h.encoder.ContentType(), body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &otlpHTTPStatusError{newHTTPStatusError(res)}
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil
+	}
+	handleOTLPPartialSuccess(h, resBody)
+	return nil
+}
+
+type otlpGRPCStatusError struct {
+	code    int
+	message string
+	delay   time.Duration
+}
+
+func (e *otlpGRPCStatusError) Error() string {
+	return fmt.Sprintf("otlp export failed with grpc status %d: %s", e.code, e.message)
+}
+
+func (e *otlpGRPCStatusError) retryable() bool {
+	switch e.code {
+	case 1, 4, 10, 11, 14, 15:
+		return true
+	case 8:
+		return e.delay > 0
+	}
+	return false
+}
+
+func (e *otlpGRPCStatusError) retryAfter() time.Duration {
+	return e.delay
+}
+
+func sendOTLPGRPC(h *HTTPTarget, body []byte) error {
+	// gRPC messages are prefixed with a compressed flag and their length.
+	compressed := byte(0)
+	if h.useGzip.Load() {
+		var err error
+		if body, err = gzipBytes(body); err != nil {
+			return err
+		}
+		compressed = 1
+	}
+	frame := make([]byte, 0, 5+len(body))
+	frame = append(frame, compressed)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(body)))
+	frame = append(frame, body...)
+
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(frame))
+	if err != nil {
+		return err
+	}
+	h.setHeaders(req)
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	if h.useGzip.Load() {
+		req.Header.Set("Grpc-Encoding", "gzip")
+	}
+
+	res, err := h.client.Load().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return &otlpHTTPStatusError{newHTTPStatusError(res)}
+	}
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	// The status is sent in the trailers, or in the headers if the response
+	// has no body.
+	status := res.Trailer.Get("Grpc-Status")
+	statusMessage := res.Trailer.Get("Grpc-Message")
+	statusDetails := res.Trailer.Get("Grpc-Status-Details-Bin")
+	if status == "" {
+		status = res.Header.Get("Grpc-Status")
+		statusMessage = res.Header.Get("Grpc-Message")
+		statusDetails = res.Header.Get("Grpc-Status-Details-Bin")
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return fmt.Errorf("otlp export received invalid grpc status %q", status)
+	}
+	if code != 0 {
+		message, _ := url.PathUnescape(statusMessage)
+		return &otlpGRPCStatusError{
+			code:    code,
+			message: message,
+			delay:   grpcRetryDelay(statusDetails),
+		}
+	}
+
+	if len(resBody) >= 5 && resBody[0] == 0 {
+		length := binary.BigEndian.Uint32(resBody[1:5])
+		if uint64(len(resBody)-5) >= uint64(length) {
+			handleOTLPPartialSuccess(h, resBody[5:5+length])
+		}
+	}
+	return nil
+}
+
+// handleOTLPPartialSuccess reads an ExportLogsServiceResponse, counting any
+// rejected records as dropped and reporting the collector's error message.
+func handleOTLPPartialSuccess(h *HTTPTarget, response []byte) {
+	var rejected uint64
+	var message string
+	// ExportLogsServiceResponse: partial_success = 1
+	protoWalk(response, func(field int, wireType int, value uint64, data []byte) {
+		if field != 1 || wireType != protoBytes {
+			return
+		}
+		// ExportLogsPartialSuccess: rejected_log_records = 1, error_message = 2
+		protoWalk(data, func(field int, wireType int, value uint64, data []byte) {
+			switch {
+			case field == 1 && wireType == protoVarint:
+				rejected = value
+			case field == 2 && wireType == protoBytes:
+				message = string(data)
+			}
+		})
+	})
+
+	if rejected > 0 {
+		h.dropped.Add(rejected)
+		reportTargetError(h, fmt.Errorf("otlp collector rejected %d log records: %s", rejected, message))
+	} else if message != "" {
+		reportTargetError(h, errors.New("otlp collector warning: "+message))
+	}
+}
+
+// grpcRetryDelay reads the retry delay from the RetryInfo detail of a
+// base64 encoded google.rpc.Status message, as found in the
+// grpc-status-details-bin trailer. It returns zero if there is none.
+func grpcRetryDelay(statusDetails string) time.Duration {
+	if statusDetails == "" {
+		return 0
+	}
+	status, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(statusDetails, "="))
+	if err != nil {
+		return 0
+	}
+
+	var delay time.Duration
+	// Status: details = 3
+	protoWalk(status, func(field int, wireType int, value uint64, data []byte) {
+		if field != 3 || wireType != protoBytes {
+			return
+		}
+		// Any: type_url = 1, value = 2
+		var typeURL string
+		var detail []byte
+		protoWalk(data, func(field int, wireType int, value uint64, data []byte) {
+			switch {
+			case field == 1 && wireType == protoBytes:
+				typeURL = string(data)
+			case field == 2 && wireType == protoBytes:
+				detail = data
+			}
+		})
+		if !strings.HasSuffix(typeURL, "/google.rpc.RetryInfo") {
+			return
+		}
+		// RetryInfo: retry_delay = 1
+		protoWalk(detail, func(field int, wireType int, value uint64, data []byte) {
+			if field != 1 || wireType != protoBytes {
+				return
+			}
+			// Duration: seconds = 1, nanos = 2
+			protoWalk(data, func(field int, wireType int, value uint64, data []byte) {
+				switch field {
+				case 1:
+					delay += time.Duration(value) * time.Second
+				case 2:
+					delay += time.Duration(value)
+				}
+			})
+		})
+	})
+	return delay
+}
@@ -0,0 +1,337 @@
+package blackbox_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+// protoMessage is a decoded protobuf message, mapping field numbers to the
+// raw values of each occurrence of the field. Varint and fixed64 values are
+// stored as uint64, and length delimited values as []byte.
+type protoMessage map[int][]any
+
+func decodeProto(t *testing.T, b []byte) protoMessage {
+	message := protoMessage{}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if !assert.Greater(t, n, 0) {
+			return message
+		}
+		b = b[n:]
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case 0:
+			value, n := binary.Uvarint(b)
+			b = b[n:]
+			message[field] = append(message[field], value)
+		case 1:
+			message[field] = append(message[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			b = b[n:]
+			message[field] = append(message[field], b[:length])
+			b = b[length:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+	return message
+}
+
+func (m protoMessage) message(t *testing.T, field int) protoMessage {
+	return decodeProto(t, m[field][0].([]byte))
+}
+
+func (m protoMessage) messages(t *testing.T, field int) []protoMessage {
+	messages := make([]protoMessage, 0)
+	for _, value := range m[field] {
+		messages = append(messages, decodeProto(t, value.([]byte)))
+	}
+	return messages
+}
+
+func (m protoMessage) str(field int) string {
+	if len(m[field]) == 0 {
+		return ""
+	}
+	return string(m[field][0].([]byte))
+}
+
+// anyValue converts an AnyValue message into a Go value.
+func anyValue(t *testing.T, m protoMessage) any {
+	switch {
+	case len(m[1]) != 0:
+		return m.str(1)
+	case len(m[2]) != 0:
+		return m[2][0].(uint64) == 1
+	case len(m[3]) != 0:
+		return int64(m[3][0].(uint64))
+	case len(m[4]) != 0:
+		return math.Float64frombits(m[4][0].(uint64))
+	case len(m[5]) != 0:
+		values := make([]any, 0)
+		for _, value := range m.message(t, 5).messages(t, 1) {
+			values = append(values, anyValue(t, value))
+		}
+		return values
+	case len(m[6]) != 0:
+		return attributes(t, m.message(t, 6), 1)
+	case len(m[7]) != 0:
+		return m[7][0].([]byte)
+	}
+	return nil
+}
+
+func attributes(t *testing.T, m protoMessage, field int) map[string]any {
+	attrs := map[string]any{}
+	for _, keyValue := range m.messages(t, field) {
+		attrs[keyValue.str(1)] = anyValue(t, keyValue.message(t, 2))
+	}
+	return attrs
+}
+
+type fakeCollector struct {
+	lock     sync.Mutex
+	records  []protoMessage
+	resource map[string]any
+}
+
+func (c *fakeCollector) receive(t *testing.T, request []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, resourceLogs := range decodeProto(t, request).messages(t, 1) {
+		c.resource = attributes(t, resourceLogs.message(t, 1), 1)
+		for _, scopeLogs := range resourceLogs.messages(t, 2) {
+			assert.Equal(t, "github.com/RobertWHurst/blackbox", scopeLogs.message(t, 1).str(1))
+			c.records = append(c.records, scopeLogs.messages(t, 2)...)
+		}
+	}
+}
+
+func (c *fakeCollector) allRecords() []protoMessage {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]protoMessage(nil), c.records...)
+}
+
+func TestOTLPTargetHTTP(t *testing.T) {
+	collector := &fakeCollector{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/v1/logs", req.URL.Path)
+		assert.Equal(t, "application/x-protobuf", req.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(req.Body)
+		collector.receive(t, body)
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer server.Close()
+
+	encoder := blackbox.NewOTLPEncoder().SetResourceAttribute("service.name", "api")
+	otlpTarget := blackbox.NewOTLPTarget(server.URL, blackbox.OTLPHTTP, encoder).
+		ShowLoggerID(true).
+		UseSource(true)
+
+	traceID := "0102030405060708090a0b0c0d0e0f10"
+	otlpTarget.Log("AAA-AAA", blackbox.Warn, []any{"Hello", "World"}, blackbox.Ctx{
+		"user":     "bob",
+		"count":    3,
+		"ratio":    0.5,
+		"ok":       true,
+		"tags":     []string{"a", "b"},
+		"request":  blackbox.Ctx{"id": 42},
+		"small":    uint64(7),
+		"large":    uint64(math.MaxUint64),
+		"trace_id": traceID,
+		"span_id":  "0102030405060708",
+	}, func() *blackbox.Source {
+		return &blackbox.Source{File: "main.go", Line: 12, Function: "main.main"}
+	})
+	otlpTarget.Log("AAA-AAA", blackbox.Verbose, []any{"Second"}, blackbox.Ctx{"trace_id": "invalid"}, nil)
+	assert.NoError(t, otlpTarget.Close(context.Background()))
+
+	assert.Equal(t, map[string]any{"service.name": "api"}, collector.resource)
+
+	records := collector.allRecords()
+	assert.Len(t, records, 2)
+
+	record := records[0]
+	assert.NotZero(t, record[1][0])
+	assert.Equal(t, record[1], record[11])
+	assert.Equal(t, uint64(13), record[2][0])
+	assert.Equal(t, "WARN", record.str(3))
+	assert.Equal(t, "Hello World", anyValue(t, record.message(t, 5)))
+	assert.Equal(t, traceID, hex.EncodeToString(record[9][0].([]byte)))
+	assert.Equal(t, "0102030405060708", hex.EncodeToString(record[10][0].([]byte)))
+	assert.Equal(t, map[string]any{
+		"user":               "bob",
+		"count":              int64(3),
+		"ratio":              0.5,
+		"ok":                 true,
+		"tags":               []any{"a", "b"},
+		"request":            map[string]any{"id": int64(42)},
+		"small":              int64(7),
+		"large":              "18446744073709551615",
+		"blackbox.logger_id": "AAA-AAA",
+		"code.file.path":     "main.go",
+		"code.line.number":   int64(12),
+		"code.function.name": "main.main",
+	}, attributes(t, record, 6))
+
+	record = records[1]
+	assert.Equal(t, uint64(8), record[2][0])
+	assert.Equal(t, "VERBOSE", record.str(3))
+	assert.Empty(t, record[9])
+	assert.Equal(t, "invalid", attributes(t, record, 6)["trace_id"])
+}
+
+func TestOTLPTargetHTTPRetry(t *testing.T) {
+	var attempts atomic.Int32
+	collector := &fakeCollector{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch attempts.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			body, _ := io.ReadAll(req.Body)
+			collector.receive(t, body)
+		}
+	}))
+	defer server.Close()
+
+	otlpTarget := blackbox.NewOTLPTarget(server.URL, blackbox.OTLPHTTP, nil).
+		SetRetry(5, time.Millisecond, time.Millisecond)
+
+	otlpTarget.Log("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil)
+	assert.NoError(t, otlpTarget.Close(context.Background()))
+
+	// 503 is retried, but 500 is not retryable under the OTLP specification.
+	assert.Equal(t, int32(2), attempts.Load())
+	assert.Len(t, collector.allRecords(), 0)
+	assert.Equal(t, uint64(1), otlpTarget.Dropped())
+}
+
+func TestOTLPTargetHTTPPartialSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.Copy(io.Discard, req.Body)
+		// ExportLogsServiceResponse{partial_success: {rejected_log_records: 1}}
+		w.Write([]byte{0x0a, 0x02, 0x08, 0x01})
+	}))
+	defer server.Close()
+
+	otlpTarget := blackbox.NewOTLPTarget(server.URL, blackbox.OTLPHTTP, nil)
+
+	otlpTarget.Log("AAA-AAA", blackbox.Info, []any{"First"}, nil, nil)
+	otlpTarget.Log("AAA-AAA", blackbox.Info, []any{"Second"}, nil, nil)
+	assert.NoError(t, otlpTarget.Close(context.Background()))
+
+	assert.Equal(t, uint64(1), otlpTarget.Dropped())
+}
+
+func newGRPCCollector(t *testing.T, handler func(w http.ResponseWriter, request []byte)) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, 2, req.ProtoMajor)
+		assert.Equal(t, "/opentelemetry.proto.collector.logs.v1.LogsService/Export", req.URL.Path)
+		assert.Equal(t, "application/grpc", req.Header.Get("Content-Type"))
+
+		body, _ := io.ReadAll(req.Body)
+		assert.Equal(t, byte(0), body[0])
+		length := binary.BigEndian.Uint32(body[1:5])
+		w.Header().Set("Content-Type", "application/grpc")
+		handler(w, body[5:5+length])
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOTLPTargetGRPC(t *testing.T) {
+	collector := &fakeCollector{}
+	server := newGRPCCollector(t, func(w http.ResponseWriter, request []byte) {
+		collector.receive(t, request)
+		w.Write([]byte{0, 0, 0, 0, 0})
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	})
+
+	otlpTarget := blackbox.NewOTLPTarget(server.URL, blackbox.OTLPGRPC, nil).
+		SetClient(server.Client())
+
+	otlpTarget.Log("AAA-AAA", blackbox.Error, []any{"Message"}, blackbox.Ctx{"user": "bob"}, nil)
+	assert.NoError(t, otlpTarget.Close(context.Background()))
+
+	records := collector.allRecords()
+	assert.Len(t, records, 1)
+	assert.Equal(t, uint64(17), records[0][2][0])
+	assert.Equal(t, "Message", anyValue(t, records[0].message(t, 5)))
+	assert.Equal(t, map[string]any{"user": "bob"}, attributes(t, records[0], 6))
+	assert.Equal(t, uint64(0), otlpTarget.Dropped())
+}
+
+func TestOTLPTargetGRPCRetry(t *testing.T) {
+	// google.rpc.Status{details: [Any{type_url: RetryInfo, value: RetryInfo{retry_delay: {nanos: 1000}}}]}
+	retryInfo := []byte{0x0a, 0x03, 0x10, 0xe8, 0x07}
+	typeURL := "type.googleapis.com/google.rpc.RetryInfo"
+	anyDetail := append([]byte{0x0a, byte(len(typeURL))}, typeURL...)
+	anyDetail = append(anyDetail, 0x12, byte(len(retryInfo)))
+	anyDetail = append(anyDetail, retryInfo...)
+	status := append([]byte{0x08, 0x08, 0x1a, byte(len(anyDetail))}, anyDetail...)
+
+	var attempts atomic.Int32
+	collector := &fakeCollector{}
+	server := newGRPCCollector(t, func(w http.ResponseWriter, request []byte) {
+		switch attempts.Add(1) {
+		case 1:
+			w.Header().Set("Grpc-Status", "14")
+			w.Header().Set("Grpc-Message", "unavailable%20now")
+		case 2:
+			w.Header().Set("Grpc-Status", "8")
+			w.Header().Set("Grpc-Status-Details-Bin", base64.RawStdEncoding.EncodeToString(status))
+		default:
+			collector.receive(t, request)
+			w.Header().Set("Grpc-Status", "0")
+		}
+	})
+
+	otlpTarget := blackbox.NewOTLPTarget(server.URL, blackbox.OTLPGRPC, nil).
+		SetClient(server.Client()).
+		SetRetry(5, time.Millisecond, time.Millisecond)
+
+	otlpTarget.Log("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil)
+	assert.NoError(t, otlpTarget.Close(context.Background()))
+
+	assert.Equal(t, int32(3), attempts.Load())
+	assert.Len(t, collector.allRecords(), 1)
+}
+
+func TestOTLPTargetGRPCPermanentError(t *testing.T) {
+	var attempts atomic.Int32
+	server := newGRPCCollector(t, func(w http.ResponseWriter, request []byte) {
+		attempts.Add(1)
+		w.Header().Set("Grpc-Status", "3")
+	})
+
+	otlpTarget := blackbox.NewOTLPTarget(server.URL, blackbox.OTLPGRPC, nil).
+		SetClient(server.Client()).
+		SetRetry(5, time.Millisecond, time.Millisecond)
+
+	otlpTarget.Log("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil)
+	assert.NoError(t, otlpTarget.Close(context.Background()))
+
+	assert.Equal(t, int32(1), attempts.Load())
+	assert.Equal(t, uint64(1), otlpTarget.Dropped())
+}
@@ -7,127 +7,130 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
-// PrettyTarget is a Target that produces newline separated human readable
-// output suitable for stdout and stderr. It also supports colorized log levels.
-type PrettyTarget struct {
-	showLoggerID  bool
-	showTimestamp bool
-	showLevel     bool
-	showContext   bool
-	useColor      bool
-	useSource     bool
-	level         Level
-	outTarget     io.Writer
-	errTarget     io.Writer
-	contextFields []string
+// PrettyEncoder is an Encoder that produces newline separated human readable
+// output. It also supports colorized log levels.
+type PrettyEncoder struct {
+	showLoggerID  atomic.Bool
+	showTimestamp atomic.Bool
+	showLevel     atomic.Bool
+	showContext   atomic.Bool
+	useColor      atomic.Bool
+	useSource     atomic.Bool
+	contextFields atomic.Pointer[[]string]
 }
 
-var _ Target = &PrettyTarget{}
+var _ FieldEncoder = &PrettyEncoder{}
+var _ TimedEncoder = &PrettyEncoder{}
 
-// NewPrettyTarget creates a PrettyTarget for use with a logger
-func NewPrettyTarget(outTarget io.Writer, errTarget io.Writer) *PrettyTarget {
-	return &PrettyTarget{
-		showTimestamp: true,
-		showLevel:     true,
-		showContext:   true,
-		useColor:      true,
-		level:         Trace,
-		outTarget:     outTarget,
-		errTarget:     errTarget,
-	}
-}
-
-// SetLevel sets the minimum log level that PrettyTarget will output. Note that
-// this setting is independent of the log level set on the logger itself.
-func (s *PrettyTarget) SetLevel(level Level) *PrettyTarget {
-	s.level = level
-	return s
+// NewPrettyEncoder creates a PrettyEncoder for use with a StreamTarget
+func NewPrettyEncoder() *PrettyEncoder {
+	encoder := &PrettyEncoder{}
+	encoder.showTimestamp.Store(true)
+	encoder.showLevel.Store(true)
+	encoder.showContext.Store(true)
+	encoder.useColor.Store(true)
+	return encoder
 }
 
 // ShowLoggerID will enable or disable logger ID values in the output depending
 // on the boolean value passed. Logger IDs can be useful when the output of
 // multiple loggers are viewed together.
-func (s *PrettyTarget) ShowLoggerID(b bool) *PrettyTarget {
-	s.showLoggerID = b
-	return s
+func (p *PrettyEncoder) ShowLoggerID(b bool) *PrettyEncoder {
+	p.showLoggerID.Store(b)
+	return p
 }
 
 // ShowTimestamp will enable or disable timestamps in the output depending on
 // the boolean value passed.
-func (s *PrettyTarget) ShowTimestamp(b bool) *PrettyTarget {
-	s.showTimestamp = b
-	return s
+func (p *PrettyEncoder) ShowTimestamp(b bool) *PrettyEncoder {
+	p.showTimestamp.Store(b)
+	return p
 }
 
 // ShowLevel will enable or disable level labels in the output depending on
 // the boolean value passed.
-func (s *PrettyTarget) ShowLevel(b bool) *PrettyTarget {
-	s.showLevel = b
-	return s
+func (p *PrettyEncoder) ShowLevel(b bool) *PrettyEncoder {
+	p.showLevel.Store(b)
+	return p
 }
 
 // SelectContext will limit the context key value pairs in the output to only
 // those specified as arguments to SelectContext. If select context is called
 // no arguments then all context key value pairs will be output.
-func (s *PrettyTarget) SelectContext(fields ...string) *PrettyTarget {
-	s.contextFields = fields
-	return s
+func (p *PrettyEncoder) SelectContext(fields ...string) *PrettyEncoder {
+	p.contextFields.Store(&fields)
+	return p
 }
 
 // ShowContext will enable or disable context key value pairs in the output
 // depending on the boolean value passed.
-func (s *PrettyTarget) ShowContext(b bool) *PrettyTarget {
-	s.showContext = b
-	return s
+func (p *PrettyEncoder) ShowContext(b bool) *PrettyEncoder {
+	p.showContext.Store(b)
+	return p
 }
 
 // UseColor will enable or disable the use of ansi color codes in the output
 // depending on the boolean value passed.
-func (s *PrettyTarget) UseColor(b bool) *PrettyTarget {
-	s.useColor = b
-	return s
+func (p *PrettyEncoder) UseColor(b bool) *PrettyEncoder {
+	p.useColor.Store(b)
+	return p
 }
 
 // ShowSource enables the inclusion of source
-func (s *PrettyTarget) ShowSource(b bool) *PrettyTarget {
-	s.useSource = b
-	return s
+func (p *PrettyEncoder) ShowSource(b bool) *PrettyEncoder {
+	p.useSource.Store(b)
+	return p
 }
 
-// Log takes a Level and series of values, then outputs them formatted
-// accordingly.
-func (s *PrettyTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
-	if level < s.level {
-		return
-	}
+// UsesSource reports whether PrettyEncoder includes the source of entries.
+func (p *PrettyEncoder) UsesSource() bool {
+	return p.useSource.Load()
+}
+
+// Encode returns the entry as a line of human readable text.
+func (p *PrettyEncoder) Encode(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) ([]byte, error) {
+	return p.EncodeFields(loggerID, level, values, context, nil, getSource)
+}
+
+// EncodeFields returns the entry as a line of human readable text, with the
+// fields sorted in among the context values.
+func (p *PrettyEncoder) EncodeFields(loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) ([]byte, error) {
+	return p.EncodeAt(time.Now(), loggerID, level, values, context, fields, getSource)
+}
+
+// EncodeAt behaves the same as EncodeFields, but uses the given time for the
+// entry.
+func (p *PrettyEncoder) EncodeAt(at time.Time, loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) ([]byte, error) {
+	useColor := p.useColor.Load()
 
 	str := ""
-	if s.showLoggerID {
+	if p.showLoggerID.Load() {
 		loggerIDStr := loggerID + " "
-		if s.useColor {
+		if useColor {
 			loggerIDStr = wrapStrInColorCodes("loggerID", loggerIDStr)
 		}
 		str += loggerIDStr
 	}
 
-	if s.showTimestamp {
-		timestampStr := time.Now().Local().Format("2006-01-02 15:04:05 MST") + " "
-		if s.useColor {
+	if p.showTimestamp.Load() {
+		timestampStr := at.Local().Format("2006-01-02 15:04:05 MST") + " "
+		if useColor {
 			timestampStr = wrapStrInColorCodes("timestamp", timestampStr)
 		}
 		str += timestampStr
 	}
 
-	if s.showLevel {
+	if p.showLevel.Load() {
 		levelStr := level.String()
 		var padStr string
 		for i := len(levelStr); i < 7; i++ {
 			padStr += " "
 		}
-		if s.useColor {
+		if useColor {
 			levelStr = wrapStrInAnsiLevelColorCodes(level, levelStr)
 		}
 		str += levelStr + padStr + " "
@@ -138,54 +141,39 @@ func (s *PrettyTarget) Log(loggerID string, level Level, values []any, context C
 		valueStrs = append(valueStrs, fmt.Sprintf("%+v", value))
 	}
 	valueStr := strings.Join(valueStrs, " ")
-	if s.useColor {
+	if useColor {
 		valueStr = wrapStrInColorCodes("value", valueStr)
 	}
 	str += valueStr
 
-	if s.showContext {
+	if p.showContext.Load() {
 		contextStrs := make([]string, 0)
 		for key, value := range context {
-			if strings.HasPrefix(key, "-") {
-				continue
-			}
-			if len(s.contextFields) != 0 {
-				skipField := true
-				for _, field := range s.contextFields {
-					if key == field {
-						skipField = false
-						break
-					}
-				}
-				if skipField {
-					continue
-				}
+			if !hasField(fields, key) && p.showsContextKey(key) {
+				contextStrs = append(contextStrs, p.formatContextPair(key, fmt.Sprintf("%+v", value), useColor))
 			}
-			if s.useColor {
-				key = wrapStrInColorCodes("contextKey", key)
-			}
-			formattedValue := strings.Replace(fmt.Sprintf("%+v", value), "\n", "\\n", -1)
-			if s.useColor {
-				formattedValue = wrapStrInColorCodes("contextValue", formattedValue)
+		}
+		for index, field := range fields {
+			if !fieldShadowed(fields, index) && p.showsContextKey(field.Key) {
+				contextStrs = append(contextStrs, p.formatContextPair(field.Key, field.format(), useColor))
 			}
-			contextStrs = append(contextStrs, key+"="+formattedValue)
 		}
 		sort.Strings(contextStrs)
 		contextStr := strings.Join(contextStrs, " ")
 		str += " " + contextStr
 	}
 
-	if s.useSource {
+	if p.useSource.Load() && getSource != nil {
 		source := getSource()
 		if source == nil {
-			return
+			return nil, nil
 		}
 		functionAndPackageName := source.Function
 		funcPathChunks := strings.Split(functionAndPackageName, "/")
 		if len(funcPathChunks) > 0 {
 			functionAndPackageName = funcPathChunks[len(funcPathChunks)-1]
 		}
-		if s.useColor {
+		if useColor {
 			chunks := strings.Split(functionAndPackageName, ".")
 			colorizedChunks := make([]string, len(chunks))
 			for i, chunk := range chunks {
@@ -201,15 +189,15 @@ func (s *PrettyTarget) Log(loggerID string, level Level, values []any, context C
 				filePath = relFilePath
 			}
 		}
-		if s.useColor {
+		if useColor {
 			filePath = wrapStrInColorCodes("filePath", filePath)
 		}
 		lineNumber := fmt.Sprintf("%d", source.Line)
-		if s.useColor {
+		if useColor {
 			lineNumber = wrapStrInColorCodes("lineNumber", lineNumber)
 		}
 		separator := "@=>"
-		if s.useColor {
+		if useColor {
 			separator = wrapStrInColorCodes("separator", separator)
 		}
 		sourceStr := fmt.Sprintf(" %s %s:%s - %s", separator, filePath, lineNumber, functionAndPackageName)
@@ -217,21 +205,175 @@ func (s *PrettyTarget) Log(loggerID string, level Level, values []any, context C
 	}
 
 	str += "\n"
-	s.writeByLevel(level, str)
+	return []byte(str), nil
+}
+
+// showsContextKey reports whether the context value under key is output.
+// Keys starting with a dash are hidden, as are keys not selected with
+// SelectContext.
+func (p *PrettyEncoder) showsContextKey(key string) bool {
+	if strings.HasPrefix(key, "-") {
+		return false
+	}
+	contextFields := p.contextFields.Load()
+	if contextFields == nil || len(*contextFields) == 0 {
+		return true
+	}
+	for _, field := range *contextFields {
+		if key == field {
+			return true
+		}
+	}
+	return false
 }
 
-func (s *PrettyTarget) writeByLevel(level Level, str string) {
-	var err error
-	if level >= Warn {
-		_, err = s.errTarget.Write([]byte(str))
-	} else {
-		_, err = s.outTarget.Write([]byte(str))
+func (p *PrettyEncoder) formatContextPair(key string, formattedValue string, useColor bool) string {
+	formattedValue = strings.Replace(formattedValue, "\n", "\\n", -1)
+	if useColor {
+		key = wrapStrInColorCodes("contextKey", key)
+		formattedValue = wrapStrInColorCodes("contextValue", formattedValue)
 	}
-	if err != nil {
-		panic(err)
+	return key + "=" + formattedValue
+}
+
+// PrettyTarget is a Target that produces newline separated human readable
+// output suitable for stdout and stderr. It also supports colorized log levels.
+// It is a StreamTarget that writes a PrettyEncoder's output to a SplitSink.
+type PrettyTarget struct {
+	encoder *PrettyEncoder
+	sink    *SplitSink
+	target  *StreamTarget
+}
+
+var _ ErrorTarget = &PrettyTarget{}
+var _ FieldTarget = &PrettyTarget{}
+var _ TimedTarget = &PrettyTarget{}
+
+// NewPrettyTarget creates a PrettyTarget for use with a logger
+func NewPrettyTarget(outTarget io.Writer, errTarget io.Writer) *PrettyTarget {
+	encoder := NewPrettyEncoder()
+	sink := NewSplitSink(outTarget, errTarget)
+	return &PrettyTarget{
+		encoder: encoder,
+		sink:    sink,
+		target:  NewStreamTarget(encoder, sink),
 	}
 }
 
+// SetLevel sets the minimum log level that PrettyTarget will output. Note that
+// this setting is independent of the log level set on the logger itself.
+func (s *PrettyTarget) SetLevel(level Level) *PrettyTarget {
+	s.target.SetLevel(level)
+	return s
+}
+
+// ShowLoggerID will enable or disable logger ID values in the output depending
+// on the boolean value passed. Logger IDs can be useful when the output of
+// multiple loggers are viewed together.
+func (s *PrettyTarget) ShowLoggerID(b bool) *PrettyTarget {
+	s.encoder.ShowLoggerID(b)
+	return s
+}
+
+// ShowTimestamp will enable or disable timestamps in the output depending on
+// the boolean value passed.
+func (s *PrettyTarget) ShowTimestamp(b bool) *PrettyTarget {
+	s.encoder.ShowTimestamp(b)
+	return s
+}
+
+// ShowLevel will enable or disable level labels in the output depending on
+// the boolean value passed.
+func (s *PrettyTarget) ShowLevel(b bool) *PrettyTarget {
+	s.encoder.ShowLevel(b)
+	return s
+}
+
+// SelectContext will limit the context key value pairs in the output to only
+// those specified as arguments to SelectContext. If select context is called
+// no arguments then all context key value pairs will be output.
+func (s *PrettyTarget) SelectContext(fields ...string) *PrettyTarget {
+	s.encoder.SelectContext(fields...)
+	return s
+}
+
+// ShowContext will enable or disable context key value pairs in the output
+// depending on the boolean value passed.
+func (s *PrettyTarget) ShowContext(b bool) *PrettyTarget {
+	s.encoder.ShowContext(b)
+	return s
+}
+
+// UseColor will enable or disable the use of ansi color codes in the output
+// depending on the boolean value passed.
+func (s *PrettyTarget) UseColor(b bool) *PrettyTarget {
+	s.encoder.UseColor(b)
+	return s
+}
+
+// ShowSource enables the inclusion of source
+func (s *PrettyTarget) ShowSource(b bool) *PrettyTarget {
+	s.encoder.ShowSource(b)
+	return s
+}
+
+// SetErrorLevel sets the minimum level of the entries written to errTarget.
+// The default is Warn. Entries below it are written to outTarget.
+func (s *PrettyTarget) SetErrorLevel(level Level) *PrettyTarget {
+	s.sink.SetErrorLevel(level)
+	return s
+}
+
+// SetRouter sets a function that picks the writer for each entry from its
+// level, in place of outTarget and errTarget. Entries for which the router
+// returns nil are discarded. See RouteByLevel for routing levels to writers
+// with a map.
+func (s *PrettyTarget) SetRouter(router func(level Level) io.Writer) *PrettyTarget {
+	s.sink.SetRouter(router)
+	return s
+}
+
+// SetRedactor sets a Redactor used to mask secrets in values and context
+// before they are output. Passing nil disables redaction.
+func (s *PrettyTarget) SetRedactor(redactor *Redactor) *PrettyTarget {
+	s.target.SetRedactor(redactor)
+	return s
+}
+
+// Enabled reports whether PrettyTarget accepts entries at the given level.
+func (s *PrettyTarget) Enabled(level Level) bool {
+	return s.target.Enabled(level)
+}
+
+// UsesSource reports whether PrettyTarget includes the source of entries.
+func (s *PrettyTarget) UsesSource() bool {
+	return s.target.UsesSource()
+}
+
+// Log takes a Level and series of values, then outputs them formatted
+// accordingly. Errors are written to stderr.
+func (s *PrettyTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	if err := s.TryLog(loggerID, level, values, context, getSource); err != nil {
+		reportTargetError(s, err)
+	}
+}
+
+// TryLog behaves the same as Log, but returns any error encountered while
+// writing the entry.
+func (s *PrettyTarget) TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return s.target.TryLog(loggerID, level, values, context, getSource)
+}
+
+// LogFields behaves the same as TryLog, but also writes the given fields.
+func (s *PrettyTarget) LogFields(loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) error {
+	return s.target.LogFields(loggerID, level, values, context, fields, getSource)
+}
+
+// LogAt behaves the same as TryLog, but uses the given time for the entry.
+func (s *PrettyTarget) LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return s.target.LogAt(at, loggerID, level, values, context, getSource)
+}
+
 func wrapStrInAnsiLevelColorCodes(level Level, str string) string {
 	switch level {
 	case Trace:
@@ -2,6 +2,7 @@ package blackbox_test
 
 import (
 	"bytes"
+	"io"
 	"testing"
 
 	"github.com/RobertWHurst/blackbox"
@@ -163,3 +164,47 @@ func TestPrettyTargetHiddenContextKeys(t *testing.T) {
 	assert.NotRegexp(t, `hidden`, outBuf.String())
 	assert.NotRegexp(t, `secret`, outBuf.String())
 }
+
+func TestPrettyTargetWriteError(t *testing.T) {
+	prettyTarget := blackbox.NewPrettyTarget(errWriter{}, errWriter{})
+
+	values := make([]any, 1)
+	values[0] = "Hello Test"
+
+	err := prettyTarget.TryLog("AAA-AAA", blackbox.Error, values, blackbox.Ctx{}, nil)
+
+	assert.EqualError(t, err, "closed pipe")
+}
+
+func TestPrettyTargetSetErrorLevel(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	prettyTarget := blackbox.NewPrettyTarget(outBuf, errBuf).
+		ShowTimestamp(false).
+		UseColor(false).
+		SetErrorLevel(blackbox.Error)
+
+	prettyTarget.Log("AAA-AAA", blackbox.Warn, []any{"Warning"}, nil, nil)
+	prettyTarget.Log("AAA-AAA", blackbox.Error, []any{"Failure"}, nil, nil)
+
+	assert.Equal(t, "warn    Warning \n", outBuf.String())
+	assert.Equal(t, "error   Failure \n", errBuf.String())
+}
+
+func TestPrettyTargetSetRouter(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	prettyTarget := blackbox.NewPrettyTarget(outBuf, outBuf).
+		ShowTimestamp(false).
+		UseColor(false).
+		SetRouter(func(level blackbox.Level) io.Writer {
+			if level < blackbox.Info {
+				return nil
+			}
+			return outBuf
+		})
+
+	prettyTarget.Log("AAA-AAA", blackbox.Debug, []any{"Dropped"}, nil, nil)
+	prettyTarget.Log("AAA-AAA", blackbox.Error, []any{"Failure"}, nil, nil)
+
+	assert.Equal(t, "error   Failure \n", outBuf.String())
+}
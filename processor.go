@@ -0,0 +1,140 @@
+package blackbox
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Entry is a log entry on its way to the targets. Processors receive entries
+// before the targets do, and may change any part of them. The Values slice and
+// Context map belong to the entry, so processors are free to modify them in
+// place.
+type Entry struct {
+	LoggerID   string
+	Level      Level
+	Values     []any
+	Context    Ctx
+	getSource  func() *Source
+	source     *Source
+	hasSource  bool
+	sourceOnce sync.Once
+}
+
+// Source returns the source of the entry. As with the getSource function
+// passed to targets, the source is only looked up when it is first asked for.
+// Once the processors have run, Source is passed on to the targets, so it is
+// safe to call from multiple goroutines.
+func (e *Entry) Source() *Source {
+	e.sourceOnce.Do(func() {
+		if !e.hasSource && e.getSource != nil {
+			e.source = e.getSource()
+		}
+		e.hasSource = true
+	})
+	return e.source
+}
+
+// SetSource replaces the source of the entry. It must only be called by
+// processors, before the entry is passed on to the targets.
+func (e *Entry) SetSource(source *Source) {
+	e.source = source
+	e.hasSource = true
+}
+
+// Processor transforms, enriches or drops entries before they reach targets.
+// Process may modify the entry it is given, and returns false if the entry
+// should be dropped.
+type Processor interface {
+	Process(entry *Entry) bool
+}
+
+// ProcessorFunc allows an ordinary function to be used as a Processor.
+type ProcessorFunc func(entry *Entry) bool
+
+// Process calls f(entry).
+func (f ProcessorFunc) Process(entry *Entry) bool {
+	return f(entry)
+}
+
+// ProcessedTarget is a Target that runs a series of processors on each entry
+// before passing it on to another target. This allows entries to be changed or
+// dropped for a single target without affecting the logger's other targets.
+type ProcessedTarget struct {
+	target     Target
+	processors []Processor
+}
+
+var _ ErrorTarget = &ProcessedTarget{}
+var _ TimedTarget = &ProcessedTarget{}
+var _ Flusher = &ProcessedTarget{}
+var _ Closer = &ProcessedTarget{}
+
+// NewProcessedTarget creates a ProcessedTarget that runs the given processors,
+// in order, on each entry before passing it on to target.
+func NewProcessedTarget(target Target, processors ...Processor) *ProcessedTarget {
+	return &ProcessedTarget{
+		target:     target,
+		processors: processors,
+	}
+}
+
+// Log runs the processors on the entry, then passes it on to the wrapped
+// target unless a processor dropped it. Errors are written to stderr.
+func (p *ProcessedTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	if err := p.TryLog(loggerID, level, values, context, getSource); err != nil {
+		reportTargetError(p, err)
+	}
+}
+
+// TryLog behaves the same as Log, but returns any error reported by the
+// wrapped target.
+func (p *ProcessedTarget) TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return p.LogAt(time.Time{}, loggerID, level, values, context, getSource)
+}
+
+// LogAt behaves the same as TryLog, but the given time is passed on to the
+// wrapped target if it implements TimedTarget.
+func (p *ProcessedTarget) LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	entry, ok := processEntry(p.processors, loggerID, level, values, context, getSource)
+	if !ok {
+		return nil
+	}
+	return logTimedToTarget(p.target, at, entry.LoggerID, entry.Level, entry.Values, entry.Context, entry.Source)
+}
+
+// Flush flushes the wrapped target if it implements Flusher.
+func (p *ProcessedTarget) Flush(ctx context.Context) error {
+	if flusher, ok := p.target.(Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return nil
+}
+
+// Close closes the wrapped target if it implements Closer, otherwise it is
+// flushed if it implements Flusher.
+func (p *ProcessedTarget) Close(ctx context.Context) error {
+	if closer, ok := p.target.(Closer); ok {
+		return closer.Close(ctx)
+	}
+	return p.Flush(ctx)
+}
+
+// processEntry builds an entry from a copy of the values and context given,
+// then runs the processors on it in order. It returns false if a processor
+// dropped the entry.
+func processEntry(processors []Processor, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) (*Entry, bool) {
+	entry := &Entry{
+		LoggerID:  loggerID,
+		Level:     level,
+		Values:    append([]any(nil), values...),
+		Context:   context.Extend(nil),
+		getSource: getSource,
+	}
+	for _, processor := range processors {
+		if !processor.Process(entry) {
+			return nil, false
+		}
+	}
+	return entry, true
+}
@@ -0,0 +1,121 @@
+package blackbox_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerAddProcessor(t *testing.T) {
+	logger := blackbox.NewWithCtx(blackbox.Ctx{"user": "bob"})
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	logger.AddProcessor(blackbox.ProcessorFunc(func(entry *blackbox.Entry) bool {
+		entry.Context["hostname"] = "host-1"
+		entry.Context["username"] = entry.Context["user"]
+		delete(entry.Context, "user")
+		return true
+	}))
+	logger.AddProcessor(blackbox.ProcessorFunc(func(entry *blackbox.Entry) bool {
+		entry.Level = blackbox.Warn
+		return true
+	}))
+
+	logger.Info("Message")
+
+	logged, ok := testTarget.LastLogged()
+
+	assert.Equal(t, true, ok)
+	assert.Equal(t, blackbox.Warn, logged.Level)
+	assert.Equal(t, blackbox.Ctx{"hostname": "host-1", "username": "bob"}, logged.Context)
+	assert.Equal(t, blackbox.Ctx{"user": "bob"}, logger.GetCtx())
+}
+
+func TestLoggerAddProcessorDrop(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	logger.AddProcessor(blackbox.ProcessorFunc(func(entry *blackbox.Entry) bool {
+		message, _ := entry.Values[0].(string)
+		return !strings.HasPrefix(message, "healthcheck")
+	}))
+
+	logger.Info("healthcheck ok")
+	logger.Info("Message")
+
+	logged := testTarget.AllLogged()
+
+	assert.Len(t, logged, 1)
+	assert.Equal(t, "Message", logged[0].Values[0])
+}
+
+func TestProcessorSource(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	var function string
+	logger.AddProcessor(blackbox.ProcessorFunc(func(entry *blackbox.Entry) bool {
+		function = entry.Source().Function
+		entry.SetSource(&blackbox.Source{Function: "replaced"})
+		return true
+	}))
+
+	logger.Info("Message")
+
+	logged, ok := testTarget.LastLogged()
+
+	assert.Equal(t, true, ok)
+	assert.Contains(t, function, "TestProcessorSource")
+	assert.Equal(t, "replaced", logged.Source.Function)
+}
+
+func TestProcessorSourceAsyncTargets(t *testing.T) {
+	logger := blackbox.New()
+	firstTarget := blackbox.NewTestTarget()
+	secondTarget := blackbox.NewTestTarget()
+	logger.AddTarget(blackbox.NewAsyncTarget(firstTarget, 10))
+	logger.AddTarget(blackbox.NewAsyncTarget(secondTarget, 10))
+	logger.AddProcessor(blackbox.ProcessorFunc(func(entry *blackbox.Entry) bool {
+		return true
+	}))
+
+	logger.Info("Message")
+	assert.NoError(t, logger.Close(context.Background()))
+
+	for _, testTarget := range []*blackbox.TestTarget{firstTarget, secondTarget} {
+		logged, ok := testTarget.LastLogged()
+		assert.Equal(t, true, ok)
+		assert.Contains(t, logged.Source.Function, "TestProcessorSourceAsyncTargets")
+	}
+}
+
+func TestProcessedTarget(t *testing.T) {
+	logger := blackbox.New()
+	processedTarget := blackbox.NewTestTarget()
+	plainTarget := blackbox.NewTestTarget()
+	logger.AddTarget(blackbox.NewProcessedTarget(processedTarget, blackbox.ProcessorFunc(func(entry *blackbox.Entry) bool {
+		entry.Values = append(entry.Values, "processed")
+		return entry.Level >= blackbox.Info
+	})))
+	logger.AddTarget(plainTarget)
+
+	logger.Debug("Dropped")
+	logger.Info("Message")
+
+	assert.Len(t, processedTarget.AllLogged(), 1)
+	assert.Len(t, plainTarget.AllLogged(), 2)
+
+	logged, ok := processedTarget.LastLogged()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []any{"Message", "processed"}, logged.Values)
+
+	logged, ok = plainTarget.LastLogged()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []any{"Message"}, logged.Values)
+}
@@ -0,0 +1,97 @@
+package blackbox
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// blackbox encodes the few protobuf messages it needs by hand, rather than
+// depending on a protobuf library. These helpers implement just enough of the
+// protobuf wire format to do so.
+
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+var errInvalidProto = errors.New("invalid protobuf message")
+
+func protoAppendTag(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func protoAppendVarint(b []byte, field int, value uint64) []byte {
+	b = protoAppendTag(b, field, protoVarint)
+	return binary.AppendUvarint(b, value)
+}
+
+func protoAppendFixed64(b []byte, field int, value uint64) []byte {
+	b = protoAppendTag(b, field, protoFixed64)
+	return binary.LittleEndian.AppendUint64(b, value)
+}
+
+func protoAppendDouble(b []byte, field int, value float64) []byte {
+	return protoAppendFixed64(b, field, math.Float64bits(value))
+}
+
+func protoAppendBytes(b []byte, field int, value []byte) []byte {
+	b = protoAppendTag(b, field, protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func protoAppendString(b []byte, field int, value string) []byte {
+	b = protoAppendTag(b, field, protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// protoWalk calls fn for each field of an encoded message. Varint and fixed
+// fields are passed as value, and length delimited fields as data.
+func protoWalk(b []byte, fn func(field int, wireType int, value uint64, data []byte)) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errInvalidProto
+		}
+		b = b[n:]
+		field := int(tag >> 3)
+		wireType := int(tag & 7)
+
+		switch wireType {
+		case protoVarint:
+			value, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errInvalidProto
+			}
+			b = b[n:]
+			fn(field, wireType, value, nil)
+		case protoFixed64:
+			if len(b) < 8 {
+				return errInvalidProto
+			}
+			fn(field, wireType, binary.LittleEndian.Uint64(b), nil)
+			b = b[8:]
+		case protoBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return errInvalidProto
+			}
+			b = b[n:]
+			fn(field, wireType, 0, b[:length])
+			b = b[length:]
+		case protoFixed32:
+			if len(b) < 4 {
+				return errInvalidProto
+			}
+			fn(field, wireType, uint64(binary.LittleEndian.Uint32(b)), nil)
+			b = b[4:]
+		default:
+			return errInvalidProto
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package blackbox
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// reconnectingConn is a network connection used by targets that send entries
+// to a server. It connects when first written to, and reconnects after a
+// failure, backing off between failed connection attempts.
+type reconnectingConn struct {
+	network    string
+	address    string
+	tlsConfig  *tls.Config
+	timeout    time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	conn       net.Conn
+	backoff    time.Duration
+	retryAt    time.Time
+	dialErr    error
+	lock       sync.Mutex
+}
+
+func newReconnectingConn(network string, address string) *reconnectingConn {
+	return &reconnectingConn{
+		network:    network,
+		address:    address,
+		timeout:    5 * time.Second,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+	}
+}
+
+func (r *reconnectingConn) setTLSConfig(config *tls.Config) {
+	r.lock.Lock()
+	r.tlsConfig = config
+	r.lock.Unlock()
+}
+
+func (r *reconnectingConn) setTimeout(timeout time.Duration) {
+	r.lock.Lock()
+	r.timeout = timeout
+	r.lock.Unlock()
+}
+
+func (r *reconnectingConn) setBackoff(min time.Duration, max time.Duration) {
+	r.lock.Lock()
+	r.minBackoff = min
+	r.maxBackoff = max
+	r.lock.Unlock()
+}
+
+// write writes each of the given messages to the connection. A stream
+// connection closed by the server is often only noticed on the next write, so
+// a failed write is retried once on a new connection.
+func (r *reconnectingConn) write(messages ...[]byte) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, message := range messages {
+		var err error
+		for attempt := 0; attempt < 2; attempt++ {
+			if r.conn == nil {
+				if err = r.connect(); err != nil {
+					return err
+				}
+			}
+			if r.timeout > 0 {
+				r.conn.SetWriteDeadline(time.Now().Add(r.timeout))
+			}
+			if _, err = r.conn.Write(message); err == nil {
+				break
+			}
+			r.conn.Close()
+			r.conn = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *reconnectingConn) close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+func (r *reconnectingConn) connect() error {
+	now := time.Now()
+	if now.Before(r.retryAt) {
+		return fmt.Errorf("%s unavailable, retrying in %s: %w", r.address, r.retryAt.Sub(now).Round(time.Millisecond), r.dialErr)
+	}
+
+	dialer := &net.Dialer{Timeout: r.timeout}
+	var conn net.Conn
+	var err error
+	if r.network == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", r.address, r.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(r.network, r.address)
+	}
+	if err != nil {
+		if r.backoff == 0 {
+			r.backoff = r.minBackoff
+		} else if r.backoff *= 2; r.backoff > r.maxBackoff {
+			r.backoff = r.maxBackoff
+		}
+		r.retryAt = now.Add(r.backoff)
+		r.dialErr = err
+		return err
+	}
+
+	r.conn = conn
+	r.backoff = 0
+	r.retryAt = time.Time{}
+	r.dialErr = nil
+	return nil
+}
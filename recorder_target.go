@@ -0,0 +1,365 @@
+package blackbox
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The recorder file starts with a fixed size header followed by a data region
+// used as a circular buffer of records. Each record is a fixed size record
+// header followed by a JSON encoded RecordedEntry. Records never wrap around
+// the end of the data region; if a record does not fit in the space left, that
+// space is zeroed and the record is written at the start of the region.
+//
+// Records are located by scanning the data region for valid record headers,
+// which means the file can be decoded even if the process died part way
+// through a write. Partially written or partially overwritten records fail
+// their checksum and are skipped.
+const (
+	recorderFileMagic        = "BBXREC\x00\x01"
+	recorderFileHeaderSize   = 32
+	recorderRecordMagic      = 0xB1AC0B0C
+	recorderRecordHeaderSize = 20
+)
+
+// ErrInvalidRecorderFile is returned by ReadRecorderFile and
+// NewRecorderTarget when a file exists but is not a recorder file.
+var ErrInvalidRecorderFile = errors.New("blackbox: invalid recorder file")
+
+// ErrRecorderSizeMismatch is returned by NewRecorderTarget when an existing
+// recorder file was created with a different size. Remove the file, or read
+// it with ReadRecorderFile first, to record with a new size.
+var ErrRecorderSizeMismatch = errors.New("blackbox: recorder file size mismatch")
+
+// ErrRecorderEntryTooLarge is returned when an entry does not fit in the
+// recorder file's data region.
+var ErrRecorderEntryTooLarge = errors.New("blackbox: entry too large for recorder file")
+
+// RecordedEntry is a log entry decoded from a recorder file.
+type RecordedEntry struct {
+	Sequence uint64    `json:"-"`
+	Time     time.Time `json:"time"`
+	LoggerID string    `json:"loggerID"`
+	Level    Level     `json:"level"`
+	Message  string    `json:"message"`
+	Context  Ctx       `json:"context,omitempty"`
+	Source   *Source   `json:"source,omitempty"`
+}
+
+// RecorderTarget is a Target that writes entries into a fixed size memory
+// mapped file used as a circular buffer. Because the file is memory mapped,
+// entries are in the operating system's page cache as soon as Log returns, so
+// the most recent entries survive the process being killed or crashing. They
+// can then be recovered with ReadRecorderFile or the blackbox-recorder
+// command.
+//
+// Note that entries are not guaranteed to survive a crash of the operating
+// system itself unless the file has been synced to disk.
+type RecorderTarget struct {
+	useSource atomic.Bool
+	level     AtomicLevel
+	file      *os.File
+	mapped    []byte
+	data      []byte
+	head      int
+	sequence  uint64
+	lock      sync.Mutex
+}
+
+var _ ErrorTarget = &RecorderTarget{}
+var _ TimedTarget = &RecorderTarget{}
+var _ Flusher = &RecorderTarget{}
+var _ Closer = &RecorderTarget{}
+
+// NewRecorderTarget creates a RecorderTarget backed by the file at path. The
+// file will be created if needed with a data region of size bytes. Note that
+// size is in bytes, not entries; each entry takes a 20 byte header plus its
+// JSON encoding, so how many entries fit depends on their size.
+//
+// If the file already contains entries from a previous run, new entries are
+// appended after them. If the file was created with a different size
+// ErrRecorderSizeMismatch is returned rather than discarding those entries,
+// and if it is not a recorder file ErrInvalidRecorderFile is returned.
+func NewRecorderTarget(path string, size int) (*RecorderTarget, error) {
+	if size < recorderRecordHeaderSize {
+		return nil, fmt.Errorf("blackbox: recorder size must be at least %d bytes", recorderRecordHeaderSize)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	fileSize := int64(recorderFileHeaderSize + size)
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() != 0 {
+		header := make([]byte, recorderFileHeaderSize)
+		if _, err := file.ReadAt(header, 0); err != nil || string(header[:len(recorderFileMagic)]) != recorderFileMagic {
+			file.Close()
+			return nil, ErrInvalidRecorderFile
+		}
+		if existingSize := binary.LittleEndian.Uint64(header[8:]); existingSize != uint64(size) || info.Size() != fileSize {
+			file.Close()
+			return nil, fmt.Errorf("%w: file holds %d bytes, requested %d", ErrRecorderSizeMismatch, existingSize, size)
+		}
+	} else if err := file.Truncate(fileSize); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	mapped, err := mapFile(file, int(fileSize))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	r := &RecorderTarget{
+		file:   file,
+		mapped: mapped,
+		data:   mapped[recorderFileHeaderSize:],
+	}
+
+	if string(mapped[:len(recorderFileMagic)]) == recorderFileMagic {
+		for _, record := range scanRecorderData(r.data) {
+			if record.sequence >= r.sequence {
+				r.sequence = record.sequence + 1
+				r.head = record.end
+			}
+		}
+	} else {
+		for i := range mapped {
+			mapped[i] = 0
+		}
+		copy(mapped, recorderFileMagic)
+		binary.LittleEndian.PutUint64(mapped[8:], uint64(size))
+	}
+
+	return r, nil
+}
+
+// SetLevel sets the minimum log level that RecorderTarget will record. Note
+// that this setting is independent of the log level set on the logger itself.
+func (r *RecorderTarget) SetLevel(level Level) *RecorderTarget {
+	r.level.SetLevel(level)
+	return r
+}
+
+// UseSource enables the inclusion of source in recorded entries
+func (r *RecorderTarget) UseSource(b bool) *RecorderTarget {
+	r.useSource.Store(b)
+	return r
+}
+
+// Enabled reports whether RecorderTarget accepts entries at the given level.
+func (r *RecorderTarget) Enabled(level Level) bool {
+	return r.level.Enabled(level)
+}
+
+// UsesSource reports whether RecorderTarget includes the source of entries.
+func (r *RecorderTarget) UsesSource() bool {
+	return r.useSource.Load()
+}
+
+// Log takes a Level and series of values, then records them in the recorder
+// file. Errors are written to stderr.
+func (r *RecorderTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	if err := r.TryLog(loggerID, level, values, context, getSource); err != nil {
+		reportTargetError(r, err)
+	}
+}
+
+// TryLog behaves the same as Log, but returns any error encountered while
+// recording the entry. Context values that can not be encoded as json are
+// replaced with their %+v formatted string.
+func (r *RecorderTarget) TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return r.LogAt(time.Now(), loggerID, level, values, context, getSource)
+}
+
+// LogAt behaves the same as TryLog, but uses the given time for the entry.
+func (r *RecorderTarget) LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	if !r.level.Enabled(level) {
+		return nil
+	}
+
+	strValues := make([]string, 0)
+	for _, value := range values {
+		strValues = append(strValues, fmt.Sprintf("%+v", value))
+	}
+	entry := RecordedEntry{
+		Time:     at,
+		LoggerID: loggerID,
+		Level:    level,
+		Message:  strings.Join(strValues, " "),
+		Context:  context,
+	}
+	if r.useSource.Load() {
+		entry.Source = getSource()
+	}
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		entry.Context = encodableContext(context, StringifyValue)
+		payload, err = json.Marshal(entry)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.data == nil {
+		return nil
+	}
+	return r.write(payload)
+}
+
+// Flush syncs the recorder file to disk so its entries survive a crash of the
+// operating system as well as the process.
+func (r *RecorderTarget) Flush(_ context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.data == nil {
+		return nil
+	}
+	return r.file.Sync()
+}
+
+// Close unmaps and closes the recorder file. Entries logged after Close are
+// discarded.
+func (r *RecorderTarget) Close(_ context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.data == nil {
+		return nil
+	}
+	err := unmapFile(r.mapped)
+	r.mapped = nil
+	r.data = nil
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (r *RecorderTarget) write(payload []byte) error {
+	recordSize := recorderRecordHeaderSize + len(payload)
+	if recordSize > len(r.data) {
+		return ErrRecorderEntryTooLarge
+	}
+
+	if r.head+recordSize > len(r.data) {
+		for i := r.head; i < len(r.data); i++ {
+			r.data[i] = 0
+		}
+		r.head = 0
+	}
+
+	record := r.data[r.head : r.head+recordSize]
+	copy(record[recorderRecordHeaderSize:], payload)
+	binary.LittleEndian.PutUint64(record[8:], r.sequence)
+	binary.LittleEndian.PutUint32(record[16:], recorderChecksum(record[8:16], payload))
+	binary.LittleEndian.PutUint32(record[4:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[0:], recorderRecordMagic)
+
+	r.head += recordSize
+	r.sequence++
+	binary.LittleEndian.PutUint64(r.mapped[16:], uint64(r.head))
+	binary.LittleEndian.PutUint64(r.mapped[24:], r.sequence)
+
+	return nil
+}
+
+// ReadRecorderFile decodes the entries held in a recorder file, oldest entry
+// first. It can be used on the file left behind by a process that crashed or
+// was killed while logging to a RecorderTarget.
+func ReadRecorderFile(path string) ([]RecordedEntry, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(contents) < recorderFileHeaderSize || string(contents[:len(recorderFileMagic)]) != recorderFileMagic {
+		return nil, ErrInvalidRecorderFile
+	}
+	size := binary.LittleEndian.Uint64(contents[8:])
+	if uint64(len(contents)) != recorderFileHeaderSize+size {
+		return nil, ErrInvalidRecorderFile
+	}
+
+	records := scanRecorderData(contents[recorderFileHeaderSize:])
+	entries := make([]RecordedEntry, 0, len(records))
+	for _, record := range records {
+		var entry RecordedEntry
+		if err := json.Unmarshal(record.payload, &entry); err != nil {
+			continue
+		}
+		entry.Sequence = record.sequence
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+type recorderRecord struct {
+	sequence uint64
+	payload  []byte
+	end      int
+}
+
+// scanRecorderData finds every valid record in the data region and returns
+// them ordered by sequence.
+func scanRecorderData(data []byte) []recorderRecord {
+	records := make([]recorderRecord, 0)
+
+	for offset := 0; offset+recorderRecordHeaderSize <= len(data); {
+		if binary.LittleEndian.Uint32(data[offset:]) != recorderRecordMagic {
+			offset++
+			continue
+		}
+		length := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		end := offset + recorderRecordHeaderSize + length
+		if length < 0 || end > len(data) {
+			offset++
+			continue
+		}
+		sequenceBytes := data[offset+8 : offset+16]
+		payload := data[offset+recorderRecordHeaderSize : end]
+		if binary.LittleEndian.Uint32(data[offset+16:]) != recorderChecksum(sequenceBytes, payload) {
+			offset++
+			continue
+		}
+		records = append(records, recorderRecord{
+			sequence: binary.LittleEndian.Uint64(sequenceBytes),
+			payload:  payload,
+			end:      end,
+		})
+		offset = end
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].sequence < records[j].sequence
+	})
+
+	return records
+}
+
+func recorderChecksum(sequenceBytes []byte, payload []byte) uint32 {
+	checksum := crc32.ChecksumIEEE(sequenceBytes)
+	return crc32.Update(checksum, crc32.IEEETable, payload)
+}
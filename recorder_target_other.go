@@ -0,0 +1,18 @@
+//go:build !unix
+
+package blackbox
+
+import (
+	"errors"
+	"os"
+)
+
+var errRecorderUnsupported = errors.New("blackbox: recorder target is not supported on this platform")
+
+func mapFile(file *os.File, size int) ([]byte, error) {
+	return nil, errRecorderUnsupported
+}
+
+func unmapFile(mapped []byte) error {
+	return errRecorderUnsupported
+}
@@ -0,0 +1,142 @@
+//go:build unix
+
+package blackbox_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorderTarget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorder.bbx")
+	recorderTarget, err := blackbox.NewRecorderTarget(path, 4096)
+	assert.NoError(t, err)
+
+	recorderTarget.Log("AAA-AAA", blackbox.Trace, []any{"Hello", "Test"}, blackbox.Ctx{"key": "value"}, nil)
+	recorderTarget.Log("AAA-AAA", blackbox.Error, []any{"Failure"}, nil, nil)
+
+	// The file is read without closing the target to simulate a crash.
+	entries, err := blackbox.ReadRecorderFile(path)
+	assert.NoError(t, err)
+
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "Hello Test", entries[0].Message)
+	assert.Equal(t, blackbox.Trace, entries[0].Level)
+	assert.Equal(t, "AAA-AAA", entries[0].LoggerID)
+	assert.Equal(t, blackbox.Ctx{"key": "value"}, entries[0].Context)
+	assert.False(t, entries[0].Time.IsZero())
+	assert.Equal(t, "Failure", entries[1].Message)
+	assert.Equal(t, blackbox.Error, entries[1].Level)
+
+	assert.NoError(t, recorderTarget.Close(context.Background()))
+}
+
+func TestRecorderTargetWrapsAround(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorder.bbx")
+	recorderTarget, err := blackbox.NewRecorderTarget(path, 1024)
+	assert.NoError(t, err)
+	defer recorderTarget.Close(context.Background())
+
+	for i := 0; i < 100; i++ {
+		recorderTarget.Log("AAA-AAA", blackbox.Info, []any{fmt.Sprintf("Message %d", i)}, nil, nil)
+	}
+
+	entries, err := blackbox.ReadRecorderFile(path)
+	assert.NoError(t, err)
+
+	assert.NotEmpty(t, entries)
+	assert.Less(t, len(entries), 100)
+	for i, entry := range entries {
+		assert.Equal(t, fmt.Sprintf("Message %d", 100-len(entries)+i), entry.Message)
+	}
+}
+
+func TestRecorderTargetReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorder.bbx")
+
+	recorderTarget, err := blackbox.NewRecorderTarget(path, 4096)
+	assert.NoError(t, err)
+	recorderTarget.Log("AAA-AAA", blackbox.Info, []any{"First run"}, nil, nil)
+	assert.NoError(t, recorderTarget.Close(context.Background()))
+
+	recorderTarget, err = blackbox.NewRecorderTarget(path, 4096)
+	assert.NoError(t, err)
+	recorderTarget.Log("BBB-BBB", blackbox.Info, []any{"Second run"}, nil, nil)
+	assert.NoError(t, recorderTarget.Close(context.Background()))
+
+	entries, err := blackbox.ReadRecorderFile(path)
+	assert.NoError(t, err)
+
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "First run", entries[0].Message)
+	assert.Equal(t, "Second run", entries[1].Message)
+}
+
+func TestRecorderTargetReopenWithDifferentSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorder.bbx")
+
+	recorderTarget, err := blackbox.NewRecorderTarget(path, 4096)
+	assert.NoError(t, err)
+	recorderTarget.Log("AAA-AAA", blackbox.Info, []any{"First run"}, nil, nil)
+	assert.NoError(t, recorderTarget.Close(context.Background()))
+
+	_, err = blackbox.NewRecorderTarget(path, 1024)
+	assert.ErrorIs(t, err, blackbox.ErrRecorderSizeMismatch)
+
+	entries, err := blackbox.ReadRecorderFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "First run", entries[0].Message)
+}
+
+func TestRecorderTargetInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.bbx")
+	assert.NoError(t, os.WriteFile(path, []byte("not a recorder file"), 0o644))
+
+	_, err := blackbox.NewRecorderTarget(path, 4096)
+	assert.ErrorIs(t, err, blackbox.ErrInvalidRecorderFile)
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "not a recorder file", string(contents))
+}
+
+func TestRecorderTargetSkipsCorruptRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorder.bbx")
+	recorderTarget, err := blackbox.NewRecorderTarget(path, 4096)
+	assert.NoError(t, err)
+
+	recorderTarget.Log("AAA-AAA", blackbox.Info, []any{"Intact"}, nil, nil)
+	recorderTarget.Log("AAA-AAA", blackbox.Info, []any{"Corrupt"}, nil, nil)
+	assert.NoError(t, recorderTarget.Close(context.Background()))
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	for i := len(contents) - 1; i >= 0; i-- {
+		if contents[i] == '}' {
+			contents[i] = ']'
+			break
+		}
+	}
+	assert.NoError(t, os.WriteFile(path, contents, 0o644))
+
+	entries, err := blackbox.ReadRecorderFile(path)
+	assert.NoError(t, err)
+
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "Intact", entries[0].Message)
+}
+
+func TestReadRecorderFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.bbx")
+	assert.NoError(t, os.WriteFile(path, []byte("not a recorder file"), 0o644))
+
+	_, err := blackbox.ReadRecorderFile(path)
+	assert.ErrorIs(t, err, blackbox.ErrInvalidRecorderFile)
+}
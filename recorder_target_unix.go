@@ -0,0 +1,16 @@
+//go:build unix
+
+package blackbox
+
+import (
+	"os"
+	"syscall"
+)
+
+func mapFile(file *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+func unmapFile(mapped []byte) error {
+	return syscall.Munmap(mapped)
+}
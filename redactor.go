@@ -0,0 +1,586 @@
+package blackbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// MaskStyle decides how a Redactor hides a secret.
+type MaskStyle int
+
+const (
+	// MaskFull replaces the secret with [REDACTED]
+	MaskFull MaskStyle = iota
+	// MaskHash replaces the secret with a short sha256 hash of it, so that
+	// entries containing the same secret can still be correlated.
+	MaskHash
+	// MaskLast4 replaces all but the last four characters of the secret with
+	// asterisks.
+	MaskLast4
+)
+
+// ValueDetector finds secrets within a string. It returns the start and end
+// byte offsets of each secret found, in the same form as
+// regexp.Regexp.FindAllStringIndex.
+type ValueDetector func(value string) [][]int
+
+var (
+	// JWTDetector finds JSON web tokens
+	JWTDetector = RegexpDetector(regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`))
+	// BearerTokenDetector finds the token in bearer authorization values
+	BearerTokenDetector = RegexpDetector(regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`))
+	// CardNumberDetector finds runs of 13 to 19 digits, optionally separated
+	// by spaces or dashes, that pass the Luhn check used by card numbers.
+	CardNumberDetector = luhnFilter(RegexpDetector(regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)))
+)
+
+// RegexpDetector creates a ValueDetector from a regular expression. If the
+// expression has a capture group, only the text matched by the first group is
+// treated as the secret, otherwise the whole match is.
+func RegexpDetector(re *regexp.Regexp) ValueDetector {
+	return func(value string) [][]int {
+		matches := re.FindAllStringSubmatchIndex(value, -1)
+		locations := make([][]int, 0, len(matches))
+		for _, match := range matches {
+			if len(match) >= 4 && match[2] >= 0 {
+				locations = append(locations, match[2:4])
+			} else {
+				locations = append(locations, match[:2])
+			}
+		}
+		return locations
+	}
+}
+
+// Redactor hides secrets in log entries. Context values with keys matching
+// one of its key patterns are masked entirely, strings are searched for
+// secrets with its value detectors, and struct fields tagged with
+// `blackbox:"redact"` are masked when the struct is formatted. Tagged structs
+// are found through pointers, slices, arrays and maps as well.
+//
+// A Redactor can be given to PrettyTarget and JSONTarget with SetRedactor, or
+// added to a logger as a Processor to apply it to every target. Rules may be
+// added while the Redactor is in use.
+type Redactor struct {
+	keyPatterns atomic.Pointer[[]string]
+	keyRegexps  atomic.Pointer[[]*regexp.Regexp]
+	detectors   atomic.Pointer[[]ValueDetector]
+	maskStyle   atomic.Int64
+	lock        sync.Mutex
+}
+
+var _ Processor = &Redactor{}
+
+// NewRedactor creates a Redactor that masks values with common secret key
+// names, such as password, token and authorization, and detects JWTs, bearer
+// tokens and card numbers.
+func NewRedactor() *Redactor {
+	return (&Redactor{}).
+		RedactKeys(
+			"*password*",
+			"*passwd*",
+			"*secret*",
+			"*token*",
+			"*api_key*",
+			"*apikey*",
+			"authorization",
+			"cookie",
+			"set-cookie",
+		).
+		DetectValues(
+			JWTDetector,
+			BearerTokenDetector,
+			CardNumberDetector,
+		)
+}
+
+// RedactKeys adds glob patterns, as understood by path.Match, for context keys
+// whose values should be masked. Patterns are matched case insensitively.
+func (r *Redactor) RedactKeys(patterns ...string) *Redactor {
+	lowerPatterns := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		lowerPatterns[i] = strings.ToLower(pattern)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	appendRedactRules(&r.keyPatterns, lowerPatterns)
+	return r
+}
+
+// RedactKeysMatching adds regular expressions for context keys whose values
+// should be masked.
+func (r *Redactor) RedactKeysMatching(res ...*regexp.Regexp) *Redactor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	appendRedactRules(&r.keyRegexps, res)
+	return r
+}
+
+// DetectValues adds detectors used to find secrets within strings.
+func (r *Redactor) DetectValues(detectors ...ValueDetector) *Redactor {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	appendRedactRules(&r.detectors, detectors)
+	return r
+}
+
+// SetMaskStyle sets how secrets are masked. The default is MaskFull.
+func (r *Redactor) SetMaskStyle(style MaskStyle) *Redactor {
+	r.maskStyle.Store(int64(style))
+	return r
+}
+
+// appendRedactRules stores a copy of the rules held by list with rules
+// appended, so that a Redactor can be configured while it is in use. The
+// redactor's lock must be held.
+func appendRedactRules[T any](list *atomic.Pointer[[]T], rules []T) {
+	var updated []T
+	if current := list.Load(); current != nil {
+		updated = append(updated, *current...)
+	}
+	updated = append(updated, rules...)
+	list.Store(&updated)
+}
+
+// loadRedactRules returns the rules held by list.
+func loadRedactRules[T any](list *atomic.Pointer[[]T]) []T {
+	if current := list.Load(); current != nil {
+		return *current
+	}
+	return nil
+}
+
+// Process redacts the values and context of the entry. It allows a Redactor
+// to be added to a logger as a processor.
+func (r *Redactor) Process(entry *Entry) bool {
+	entry.Values = r.RedactValues(entry.Values)
+	entry.Context = r.RedactCtx(entry.Context)
+	return true
+}
+
+// RedactValues returns a copy of values with each value redacted.
+func (r *Redactor) RedactValues(values []any) []any {
+	redactedValues := make([]any, len(values))
+	for i, value := range values {
+		redactedValues[i] = r.RedactValue(value)
+	}
+	return redactedValues
+}
+
+// RedactCtx returns a copy of context with secrets masked. Nested Ctx values
+// are redacted as well.
+func (r *Redactor) RedactCtx(context Ctx) Ctx {
+	if context == nil {
+		return nil
+	}
+	redactedContext := make(Ctx, len(context))
+	for key, value := range context {
+		if r.isSecretKey(key) {
+			redactedContext[key] = r.Mask(fmt.Sprintf("%+v", value))
+			continue
+		}
+		redactedContext[key] = r.RedactValue(value)
+	}
+	return redactedContext
+}
+
+// RedactFields returns a copy of fields with secrets masked, in the same way
+// as RedactCtx masks context values.
+func (r *Redactor) RedactFields(fields []Field) []Field {
+	if fields == nil {
+		return nil
+	}
+	redactedFields := make([]Field, len(fields))
+	for i, field := range fields {
+		switch {
+		case field.kind == fieldSkip:
+			redactedFields[i] = field
+		case r.isSecretKey(field.Key):
+			redactedFields[i] = String(field.Key, r.Mask(field.format()))
+		case field.kind == fieldString:
+			redactedFields[i] = String(field.Key, r.redactString(field.str))
+		case field.kind == fieldError:
+			redacted := r.RedactValue(field.value)
+			if err, ok := redacted.(error); ok {
+				redactedFields[i] = Field{Key: field.Key, kind: fieldError, value: err}
+			} else {
+				redactedFields[i] = Any(field.Key, redacted)
+			}
+		case field.kind == fieldAny:
+			redactedFields[i] = Any(field.Key, r.RedactValue(field.value))
+		default:
+			redactedFields[i] = field
+		}
+	}
+	return redactedFields
+}
+
+// RedactValue returns value with any secrets it holds masked. Values that do
+// not hold secrets are returned unchanged.
+func (r *Redactor) RedactValue(value any) any {
+	switch typedValue := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return r.redactString(typedValue)
+	case Ctx:
+		return r.RedactCtx(typedValue)
+	case map[string]any:
+		return map[string]any(r.RedactCtx(typedValue))
+	case []any:
+		return r.RedactValues(typedValue)
+	case error:
+		return r.redactStringer(value, typedValue.Error())
+	case fmt.Stringer:
+		return r.redactStringer(value, typedValue.String())
+	}
+
+	reflectValue := reflect.ValueOf(value)
+	if !hasRedactTags(reflectValue.Type()) {
+		return value
+	}
+	return r.redactReflect(reflectValue, map[redactVisit]bool{})
+}
+
+// Mask hides a secret according to the redactor's mask style.
+func (r *Redactor) Mask(secret string) string {
+	switch MaskStyle(r.maskStyle.Load()) {
+	case MaskHash:
+		sum := sha256.Sum256([]byte(secret))
+		return "sha256:" + hex.EncodeToString(sum[:])[:16]
+	case MaskLast4:
+		runes := []rune(secret)
+		if len(runes) <= 4 {
+			return "****"
+		}
+		return "****" + string(runes[len(runes)-4:])
+	}
+	return "[REDACTED]"
+}
+
+func (r *Redactor) isSecretKey(key string) bool {
+	lowerKey := strings.ToLower(key)
+	for _, pattern := range loadRedactRules(&r.keyPatterns) {
+		if matched, _ := path.Match(pattern, lowerKey); matched {
+			return true
+		}
+	}
+	for _, re := range loadRedactRules(&r.keyRegexps) {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) redactString(value string) string {
+	for _, detector := range loadRedactRules(&r.detectors) {
+		locations := detector(value)
+		if len(locations) == 0 {
+			continue
+		}
+		var builder strings.Builder
+		last := 0
+		for _, location := range locations {
+			if location[0] < last {
+				continue
+			}
+			builder.WriteString(value[last:location[0]])
+			builder.WriteString(r.Mask(value[location[0]:location[1]]))
+			last = location[1]
+		}
+		builder.WriteString(value[last:])
+		value = builder.String()
+	}
+	return value
+}
+
+// redactStringer returns the redacted string form of value if it contained a
+// secret, otherwise value itself.
+func (r *Redactor) redactStringer(value any, str string) any {
+	if redactedStr := r.redactString(str); redactedStr != str {
+		return redactedStr
+	}
+	return value
+}
+
+// redactReflect returns a copy of value with the fields tagged
+// `blackbox:"redact"` in any structs it holds masked. visiting holds the
+// pointers and slices currently being followed, so that cyclic values
+// terminate; a reference back into the value is replaced with its address.
+func (r *Redactor) redactReflect(value reflect.Value, visiting map[redactVisit]bool) any {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return nil
+		}
+		visit := redactVisit{pointer: value.Pointer(), valueType: value.Type()}
+		if visiting[visit] {
+			return fmt.Sprintf("%#x", visit.pointer)
+		}
+		visiting[visit] = true
+		defer delete(visiting, visit)
+		redacted := r.redactReflect(value.Elem(), visiting)
+		if redactedStruct, ok := redacted.(redactedStruct); ok {
+			redactedStruct.pointer = true
+			return redactedStruct
+		}
+		return redacted
+
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice {
+			if value.IsNil() {
+				return value.Interface()
+			}
+			visit := redactVisit{pointer: value.Pointer(), valueType: value.Type()}
+			if visiting[visit] {
+				return fmt.Sprintf("%#x", visit.pointer)
+			}
+			visiting[visit] = true
+			defer delete(visiting, visit)
+		}
+		redactedValues := make([]any, value.Len())
+		for i := range redactedValues {
+			redactedValues[i] = r.redactReflect(value.Index(i), visiting)
+		}
+		return redactedValues
+
+	case reflect.Map:
+		if value.IsNil() {
+			return value.Interface()
+		}
+		redactedMap := reflect.MakeMapWithSize(reflect.MapOf(value.Type().Key(), anyType), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			redactedValue := r.redactReflect(iter.Value(), visiting)
+			if redactedValue == nil {
+				redactedMap.SetMapIndex(iter.Key(), reflect.Zero(anyType))
+			} else {
+				redactedMap.SetMapIndex(iter.Key(), reflect.ValueOf(redactedValue))
+			}
+		}
+		return redactedMap.Interface()
+
+	case reflect.Struct:
+		return r.redactStruct(value, visiting)
+
+	case reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return r.redactReflect(value.Elem(), visiting)
+	}
+
+	if !value.CanInterface() {
+		return value
+	}
+	return r.RedactValue(value.Interface())
+}
+
+func (r *Redactor) redactStruct(value reflect.Value, visiting map[redactVisit]bool) any {
+	valueType := value.Type()
+	if !hasRedactTags(valueType) {
+		if value.CanInterface() {
+			return value.Interface()
+		}
+		return value
+	}
+
+	redacted := redactedStruct{fields: make([]redactedField, 0, valueType.NumField())}
+	for i := 0; i < valueType.NumField(); i++ {
+		structField := valueType.Field(i)
+		fieldValue := value.Field(i)
+		field := redactedField{
+			name:     structField.Name,
+			jsonName: structField.Name,
+			jsonSkip: !structField.IsExported(),
+			isZero:   fieldValue.IsZero(),
+		}
+		if jsonTag, ok := structField.Tag.Lookup("json"); ok {
+			name, options, _ := strings.Cut(jsonTag, ",")
+			if name == "-" && options == "" {
+				field.jsonSkip = true
+			} else if name != "" {
+				field.jsonName = name
+			}
+			field.omitEmpty = strings.Contains(","+options+",", ",omitempty,")
+		}
+
+		switch {
+		case structField.Tag.Get("blackbox") == "redact":
+			field.value = r.Mask(fmt.Sprintf("%+v", fieldValue))
+		case !structField.IsExported():
+			field.value = fieldValue
+		case hasRedactTags(structField.Type):
+			field.value = r.redactReflect(fieldValue, visiting)
+		default:
+			field.value = r.RedactValue(fieldValue.Interface())
+		}
+		redacted.fields = append(redacted.fields, field)
+	}
+	return redacted
+}
+
+var redactTagCache sync.Map
+
+var anyType = reflect.TypeOf((*any)(nil)).Elem()
+
+// redactVisit identifies a pointer or slice being followed by redactReflect.
+type redactVisit struct {
+	pointer   uintptr
+	valueType reflect.Type
+}
+
+// hasRedactTags reports whether t is a struct with a field tagged
+// `blackbox:"redact"`, either directly or in a nested struct, or a pointer,
+// slice, array or map holding such structs.
+func hasRedactTags(t reflect.Type) bool {
+	if cached, ok := redactTagCache.Load(t); ok {
+		return cached.(bool)
+	}
+	hasTags, _ := searchRedactTags(t, map[reflect.Type]bool{})
+	redactTagCache.Store(t, hasTags)
+	return hasTags
+}
+
+// searchRedactTags reports whether t has redact tags, skipping the types in
+// visiting so that recursive types terminate. Skipping a type can hide tags
+// that are only reachable through it, so a false result is reported as final
+// only if no type was skipped. Only final results are cached.
+func searchRedactTags(t reflect.Type, visiting map[reflect.Type]bool) (hasTags bool, final bool) {
+	if cached, ok := redactTagCache.Load(t); ok {
+		return cached.(bool), true
+	}
+	if visiting[t] {
+		return false, false
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	final = true
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		hasTags, final = searchRedactTags(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField() && !hasTags; i++ {
+			field := t.Field(i)
+			if field.Tag.Get("blackbox") == "redact" {
+				hasTags = true
+			} else if field.IsExported() {
+				fieldHasTags, fieldFinal := searchRedactTags(field.Type, visiting)
+				hasTags = fieldHasTags
+				final = final && fieldFinal
+			}
+		}
+	}
+	if hasTags || final {
+		redactTagCache.Store(t, hasTags)
+		return hasTags, true
+	}
+	return false, false
+}
+
+// redactedStruct stands in for a struct with redacted fields. It formats the
+// same way fmt formats structs, and encodes to json the same way
+// encoding/json encodes structs, but with secrets masked.
+type redactedStruct struct {
+	pointer bool
+	fields  []redactedField
+}
+
+type redactedField struct {
+	name      string
+	jsonName  string
+	jsonSkip  bool
+	omitEmpty bool
+	isZero    bool
+	value     any
+}
+
+func (r redactedStruct) Format(f fmt.State, verb rune) {
+	if r.pointer {
+		fmt.Fprint(f, "&")
+	}
+	fmt.Fprint(f, "{")
+	for i, field := range r.fields {
+		if i != 0 {
+			fmt.Fprint(f, " ")
+		}
+		if f.Flag('+') {
+			fmt.Fprintf(f, "%s:%+v", field.name, field.value)
+		} else {
+			fmt.Fprintf(f, "%v", field.value)
+		}
+	}
+	fmt.Fprint(f, "}")
+}
+
+func (r redactedStruct) MarshalJSON() ([]byte, error) {
+	var buf strings.Builder
+	buf.WriteString("{")
+	first := true
+	for _, field := range r.fields {
+		if field.jsonSkip || (field.omitEmpty && field.isZero) {
+			continue
+		}
+		name, err := json.Marshal(field.jsonName)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		if !first {
+			buf.WriteString(",")
+		}
+		first = false
+		buf.Write(name)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return []byte(buf.String()), nil
+}
+
+func luhnFilter(detector ValueDetector) ValueDetector {
+	return func(value string) [][]int {
+		locations := detector(value)
+		valid := locations[:0]
+		for _, location := range locations {
+			if passesLuhn(value[location[0]:location[1]]) {
+				valid = append(valid, location)
+			}
+		}
+		return valid
+	}
+}
+
+func passesLuhn(value string) bool {
+	sum := 0
+	double := false
+	for i := len(value) - 1; i >= 0; i-- {
+		c := value[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,144 @@
+package blackbox
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// RingTarget is a Target that records the most recent log entries in a bounded
+// in-memory ring. When an entry at or above the trigger level arrives, the
+// recorded history, followed by the triggering entry, is dumped to another
+// target. This makes it possible to see the trace and debug lead-up to a
+// failure without writing every trace entry during normal operation.
+//
+// RingTarget records entries of every level. The dump target's own level is
+// still respected when the history is dumped to it, so it should usually be
+// set to Trace. The dump target should also not be added to the logger
+// directly, otherwise the triggering entry will be written twice.
+type RingTarget struct {
+	size         int
+	triggerLevel Level
+	dumpTarget   Target
+	entries      []ringEntry
+	next         int
+	full         bool
+	lock         sync.Mutex
+}
+
+type ringEntry struct {
+	at        time.Time
+	loggerID  string
+	level     Level
+	values    []any
+	context   Ctx
+	getSource func() *Source
+}
+
+var _ ErrorTarget = &RingTarget{}
+var _ TimedTarget = &RingTarget{}
+
+// NewRingTarget creates a RingTarget that keeps the last size entries and
+// dumps them to dumpTarget when an Error level entry or above is logged.
+func NewRingTarget(size int, dumpTarget Target) *RingTarget {
+	if size < 1 {
+		size = 1
+	}
+	return &RingTarget{
+		size:         size,
+		triggerLevel: Error,
+		dumpTarget:   dumpTarget,
+		entries:      make([]ringEntry, size),
+	}
+}
+
+// SetTriggerLevel sets the minimum level of an entry that will cause the
+// recorded history to be dumped.
+func (r *RingTarget) SetTriggerLevel(level Level) *RingTarget {
+	r.lock.Lock()
+	r.triggerLevel = level
+	r.lock.Unlock()
+	return r
+}
+
+// Log records the entry in the ring. If the entry is at or above the trigger
+// level, the ring is dumped to the dump target and then emptied. Errors from
+// the dump target are written to stderr.
+func (r *RingTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	if err := r.TryLog(loggerID, level, values, context, getSource); err != nil {
+		reportTargetError(r, err)
+	}
+}
+
+// TryLog behaves the same as Log, but returns any errors reported by the dump
+// target.
+func (r *RingTarget) TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return r.LogAt(time.Now(), loggerID, level, values, context, getSource)
+}
+
+// LogAt behaves the same as TryLog, but uses the given time for the entry.
+// Entries are dumped with the time they were recorded, which is passed on to
+// the dump target if it implements TimedTarget.
+func (r *RingTarget) LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.entries[r.next] = ringEntry{
+		at:        at,
+		loggerID:  loggerID,
+		level:     level,
+		values:    values,
+		context:   context,
+		getSource: getSource,
+	}
+	r.next = (r.next + 1) % r.size
+	if r.next == 0 {
+		r.full = true
+	}
+
+	if level >= r.triggerLevel {
+		return r.dump()
+	}
+	return nil
+}
+
+// Dump writes the recorded history to the dump target, oldest entry first,
+// and empties the ring. Any errors reported by the dump target are returned.
+func (r *RingTarget) Dump() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.dump()
+}
+
+// Len returns the number of entries currently recorded.
+func (r *RingTarget) Len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.full {
+		return r.size
+	}
+	return r.next
+}
+
+func (r *RingTarget) dump() error {
+	start := 0
+	count := r.next
+	if r.full {
+		start = r.next
+		count = r.size
+	}
+
+	var errs []error
+	for i := 0; i < count; i++ {
+		index := (start + i) % r.size
+		entry := r.entries[index]
+		r.entries[index] = ringEntry{}
+		if err := logTimedToTarget(r.dumpTarget, entry.at, entry.loggerID, entry.level, entry.values, entry.context, entry.getSource); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	r.next = 0
+	r.full = false
+	return errors.Join(errs...)
+}
@@ -0,0 +1,91 @@
+package blackbox_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingTargetDumpsOnTrigger(t *testing.T) {
+	dumpTarget := blackbox.NewTestTarget()
+	ringTarget := blackbox.NewRingTarget(10, dumpTarget)
+
+	ringTarget.Log("AAA-AAA", blackbox.Trace, []any{"one"}, nil, nil)
+	ringTarget.Log("AAA-AAA", blackbox.Debug, []any{"two"}, nil, nil)
+
+	assert.Empty(t, dumpTarget.AllLogged())
+	assert.Equal(t, 2, ringTarget.Len())
+
+	ringTarget.Log("AAA-AAA", blackbox.Error, []any{"three"}, nil, nil)
+
+	logged := dumpTarget.AllLogged()
+	assert.Len(t, logged, 3)
+	assert.Equal(t, []any{"one"}, logged[0].Values)
+	assert.Equal(t, []any{"two"}, logged[1].Values)
+	assert.Equal(t, []any{"three"}, logged[2].Values)
+	assert.Equal(t, blackbox.Error, logged[2].Level)
+	assert.Equal(t, 0, ringTarget.Len())
+}
+
+func TestRingTargetKeepsLastEntries(t *testing.T) {
+	dumpTarget := blackbox.NewTestTarget()
+	ringTarget := blackbox.NewRingTarget(2, dumpTarget)
+
+	ringTarget.Log("AAA-AAA", blackbox.Trace, []any{"one"}, nil, nil)
+	ringTarget.Log("AAA-AAA", blackbox.Trace, []any{"two"}, nil, nil)
+	ringTarget.Log("AAA-AAA", blackbox.Trace, []any{"three"}, nil, nil)
+	ringTarget.Dump()
+
+	logged := dumpTarget.AllLogged()
+	assert.Len(t, logged, 2)
+	assert.Equal(t, []any{"two"}, logged[0].Values)
+	assert.Equal(t, []any{"three"}, logged[1].Values)
+}
+
+func TestRingTargetSetTriggerLevel(t *testing.T) {
+	dumpTarget := blackbox.NewTestTarget()
+	ringTarget := blackbox.NewRingTarget(10, dumpTarget)
+
+	ringTarget.SetTriggerLevel(blackbox.Warn)
+
+	ringTarget.Log("AAA-AAA", blackbox.Info, []any{"one"}, nil, nil)
+	assert.Empty(t, dumpTarget.AllLogged())
+
+	ringTarget.Log("AAA-AAA", blackbox.Warn, []any{"two"}, nil, nil)
+	assert.Len(t, dumpTarget.AllLogged(), 2)
+}
+
+func TestRingTargetWithLogger(t *testing.T) {
+	logger := blackbox.New()
+	dumpTarget := blackbox.NewTestTarget()
+	logger.AddTarget(blackbox.NewRingTarget(10, dumpTarget))
+
+	logger.Debug("Message", blackbox.Ctx{"key": "value"})
+	logger.Error("Failure")
+
+	logged, ok := dumpTarget.PreviouslyLogged(1)
+
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Message", logged.Values[0])
+	assert.Equal(t, blackbox.Ctx{"key": "value"}, logged.Context)
+	assert.Contains(t, logged.Source.Function, "TestRingTargetWithLogger")
+}
+
+func TestRingTargetKeepsEntryTimes(t *testing.T) {
+	dumpTarget := &timedTarget{}
+	ringTarget := blackbox.NewRingTarget(10, dumpTarget)
+
+	recordTime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	assert.NoError(t, ringTarget.LogAt(recordTime, "AAA-AAA", blackbox.Trace, []any{"one"}, nil, nil))
+	ringTarget.Log("AAA-AAA", blackbox.Debug, []any{"two"}, nil, nil)
+	time.Sleep(20 * time.Millisecond)
+	triggeredAt := time.Now()
+	ringTarget.Log("AAA-AAA", blackbox.Error, []any{"three"}, nil, nil)
+
+	assert.Len(t, dumpTarget.times, 3)
+	assert.Equal(t, recordTime, dumpTarget.times[0])
+	assert.True(t, dumpTarget.times[1].Before(triggeredAt))
+	assert.False(t, dumpTarget.times[2].Before(triggeredAt))
+}
@@ -0,0 +1,414 @@
+package blackbox
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// rotatedTimeFormat is used to name rotated files. It sorts in the same order
+// as the times it represents.
+const rotatedTimeFormat = "2006-01-02T15-04-05.000000000"
+
+// SyncPolicy decides how often a RotatingWriter syncs its file to disk.
+type SyncPolicy int
+
+const (
+	// SyncNever leaves syncing to the operating system
+	SyncNever SyncPolicy = iota
+	// SyncAlways syncs after every write
+	SyncAlways
+	// SyncPeriodically syncs on the first write after the sync interval has
+	// passed
+	SyncPeriodically
+)
+
+// RotatingWriter is an io.Writer that writes to a file, rotating it once it
+// reaches a maximum size or a wall clock interval passes. Rotated files are
+// renamed with a timestamp, optionally compressed with gzip in the background,
+// and removed once there are too many of them or they are too old.
+//
+// RotatingWriter can be used as the writer of any target, for example:
+//
+//	writer, err := blackbox.NewRotatingWriter("/var/log/app/app.log")
+//	logger.AddTarget(blackbox.NewJSONTarget(writer, writer))
+type RotatingWriter struct {
+	path           string
+	maxSize        int64
+	interval       time.Duration
+	compress       bool
+	maxFiles       int
+	maxAge         time.Duration
+	syncPolicy     SyncPolicy
+	syncInterval   time.Duration
+	file           *os.File
+	size           int64
+	openedAt       time.Time
+	lastSync       time.Time
+	signals        chan os.Signal
+	lock           sync.Mutex
+	background     sync.WaitGroup
+	backgroundLock sync.Mutex
+}
+
+var _ io.WriteCloser = &RotatingWriter{}
+
+// NewRotatingWriter creates a RotatingWriter that appends to the file at path,
+// creating it, and any missing directories, if needed. By default the file is
+// never rotated; use SetMaxSize or SetInterval to enable rotation.
+func NewRotatingWriter(path string) (*RotatingWriter, error) {
+	r := &RotatingWriter{
+		path:         path,
+		syncInterval: time.Second,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetMaxSize sets the size in bytes at which the file is rotated. A size of
+// zero disables size based rotation.
+func (r *RotatingWriter) SetMaxSize(size int64) *RotatingWriter {
+	r.lock.Lock()
+	r.maxSize = size
+	r.lock.Unlock()
+	return r
+}
+
+// SetInterval sets a wall clock interval at which the file is rotated. Files
+// are rotated on the first write after each interval boundary, with
+// boundaries aligned to UTC, so an interval of 24 hours rotates at midnight
+// UTC. An interval of zero disables time based rotation.
+func (r *RotatingWriter) SetInterval(interval time.Duration) *RotatingWriter {
+	r.lock.Lock()
+	r.interval = interval
+	r.lock.Unlock()
+	return r
+}
+
+// SetCompress will enable or disable gzip compression of rotated files
+// depending on the boolean value passed. Compression happens in the
+// background.
+func (r *RotatingWriter) SetCompress(b bool) *RotatingWriter {
+	r.lock.Lock()
+	r.compress = b
+	r.lock.Unlock()
+	return r
+}
+
+// SetMaxFiles sets the number of rotated files to keep. Older files are
+// removed. Zero keeps all files.
+func (r *RotatingWriter) SetMaxFiles(n int) *RotatingWriter {
+	r.lock.Lock()
+	r.maxFiles = n
+	r.lock.Unlock()
+	return r
+}
+
+// SetMaxAge sets how long rotated files are kept for. Zero keeps files
+// regardless of age.
+func (r *RotatingWriter) SetMaxAge(age time.Duration) *RotatingWriter {
+	r.lock.Lock()
+	r.maxAge = age
+	r.lock.Unlock()
+	return r
+}
+
+// SetSyncPolicy sets how often the file is synced to disk. The default is
+// SyncNever.
+func (r *RotatingWriter) SetSyncPolicy(policy SyncPolicy) *RotatingWriter {
+	r.lock.Lock()
+	r.syncPolicy = policy
+	r.lock.Unlock()
+	return r
+}
+
+// SetSyncInterval sets the interval used by SyncPeriodically. The default is
+// one second.
+func (r *RotatingWriter) SetSyncInterval(interval time.Duration) *RotatingWriter {
+	r.lock.Lock()
+	r.syncInterval = interval
+	r.lock.Unlock()
+	return r
+}
+
+// ReopenOnSignal reopens the file whenever one of the given signals is
+// received, or SIGHUP if none are given. This allows an external tool such as
+// logrotate to move the file out of the way.
+func (r *RotatingWriter) ReopenOnSignal(signals ...os.Signal) *RotatingWriter {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.signals != nil {
+		signal.Stop(r.signals)
+		close(r.signals)
+	}
+	r.signals = make(chan os.Signal, 1)
+	signal.Notify(r.signals, signals...)
+
+	go func(signals chan os.Signal) {
+		for range signals {
+			r.Reopen()
+		}
+	}(r.signals)
+
+	return r
+}
+
+// Write writes p to the file, rotating it first if needed.
+func (r *RotatingWriter) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	now := time.Now()
+	if r.shouldRotate(now, int64(len(p))) {
+		if err := r.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+
+	switch r.syncPolicy {
+	case SyncAlways:
+		err = r.file.Sync()
+	case SyncPeriodically:
+		if now.Sub(r.lastSync) >= r.syncInterval {
+			err = r.file.Sync()
+			r.lastSync = now
+		}
+	}
+	return n, err
+}
+
+// Rotate rotates the file immediately.
+func (r *RotatingWriter) Rotate() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.rotate(time.Now())
+}
+
+// Reopen closes and reopens the file at the writer's path. It should be called
+// after the file has been moved by an external tool.
+func (r *RotatingWriter) Reopen() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// Sync syncs the file to disk.
+func (r *RotatingWriter) Sync() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.file.Sync()
+}
+
+// Close closes the file, and waits for any background compression and
+// removal of rotated files to finish.
+func (r *RotatingWriter) Close() error {
+	r.lock.Lock()
+	if r.signals != nil {
+		signal.Stop(r.signals)
+		close(r.signals)
+		r.signals = nil
+	}
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.lock.Unlock()
+
+	r.background.Wait()
+	return err
+}
+
+func (r *RotatingWriter) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = time.Now()
+	return nil
+}
+
+func (r *RotatingWriter) shouldRotate(now time.Time, writeSize int64) bool {
+	if r.maxSize > 0 && r.size > 0 && r.size+writeSize > r.maxSize {
+		return true
+	}
+	if r.interval > 0 && !now.Truncate(r.interval).Equal(r.openedAt.Truncate(r.interval)) {
+		return true
+	}
+	return false
+}
+
+func (r *RotatingWriter) rotate(now time.Time) error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(r.path)
+	rotatedPath := strings.TrimSuffix(r.path, ext) + "-" + now.UTC().Format(rotatedTimeFormat) + ext
+	if err := os.Rename(r.path, rotatedPath); err != nil {
+		if openErr := r.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	compress := r.compress
+	maxFiles := r.maxFiles
+	maxAge := r.maxAge
+	r.background.Add(1)
+	go func() {
+		defer r.background.Done()
+		r.backgroundLock.Lock()
+		defer r.backgroundLock.Unlock()
+
+		if compress {
+			if err := compressFile(rotatedPath); err != nil {
+				reportRotationError(err)
+			}
+		}
+		if err := r.removeOldFiles(now, maxFiles, maxAge); err != nil {
+			reportRotationError(err)
+		}
+	}()
+
+	return nil
+}
+
+// rotatedFiles returns the paths of the rotated files belonging to the writer,
+// newest first.
+func (r *RotatingWriter) rotatedFiles() ([]string, error) {
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(filepath.Base(r.path), ext) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		timestamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		if _, err := time.Parse(rotatedTimeFormat, timestamp); err != nil {
+			continue
+		}
+		paths = append(paths, filepath.Join(filepath.Dir(r.path), name))
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	return paths, nil
+}
+
+func (r *RotatingWriter) removeOldFiles(now time.Time, maxFiles int, maxAge time.Duration) error {
+	if maxFiles <= 0 && maxAge <= 0 {
+		return nil
+	}
+
+	paths, err := r.rotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, path := range paths {
+		remove := maxFiles > 0 && i >= maxFiles
+		if !remove && maxAge > 0 {
+			info, err := os.Stat(path)
+			remove = err == nil && now.Sub(info.ModTime()) > maxAge
+		}
+		if remove {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func compressFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gzipWriter := gzip.NewWriter(destination)
+	_, err = io.Copy(gzipWriter, source)
+	if closeErr := gzipWriter.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	source.Close()
+	return os.Remove(path)
+}
+
+func reportRotationError(err error) {
+	os.Stderr.WriteString("blackbox: failed to process rotated log file: " + err.Error() + "\n")
+}
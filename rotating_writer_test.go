@@ -0,0 +1,195 @@
+package blackbox_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func rotatedFiles(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+
+	names := make([]string, 0)
+	for _, entry := range entries {
+		if entry.Name() != "app.log" {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotatingWriterMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	writer, err := blackbox.NewRotatingWriter(path)
+	assert.NoError(t, err)
+	writer.SetMaxSize(10)
+
+	_, err = writer.Write([]byte("first\n"))
+	assert.NoError(t, err)
+	_, err = writer.Write([]byte("second\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	current, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "second\n", string(current))
+
+	rotated := rotatedFiles(t, dir)
+	assert.Len(t, rotated, 1)
+	assert.True(t, strings.HasPrefix(rotated[0], "app-"))
+	assert.True(t, strings.HasSuffix(rotated[0], ".log"))
+
+	previous, err := os.ReadFile(filepath.Join(dir, rotated[0]))
+	assert.NoError(t, err)
+	assert.Equal(t, "first\n", string(previous))
+}
+
+func TestRotatingWriterInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	writer, err := blackbox.NewRotatingWriter(path)
+	assert.NoError(t, err)
+	writer.SetInterval(50 * time.Millisecond)
+
+	_, err = writer.Write([]byte("first\n"))
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = writer.Write([]byte("second\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	current, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "second\n", string(current))
+	assert.Len(t, rotatedFiles(t, dir), 1)
+}
+
+func TestRotatingWriterCompress(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	writer, err := blackbox.NewRotatingWriter(path)
+	assert.NoError(t, err)
+	writer.SetCompress(true)
+
+	_, err = writer.Write([]byte("first\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Rotate())
+	assert.NoError(t, writer.Close())
+
+	rotated := rotatedFiles(t, dir)
+	assert.Len(t, rotated, 1)
+	assert.True(t, strings.HasSuffix(rotated[0], ".log.gz"))
+
+	file, err := os.Open(filepath.Join(dir, rotated[0]))
+	assert.NoError(t, err)
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	assert.NoError(t, err)
+	contents, err := io.ReadAll(gzipReader)
+	assert.NoError(t, err)
+	assert.Equal(t, "first\n", string(contents))
+}
+
+func TestRotatingWriterMaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	writer, err := blackbox.NewRotatingWriter(path)
+	assert.NoError(t, err)
+	writer.SetMaxFiles(2)
+
+	for i := 0; i < 5; i++ {
+		_, err = writer.Write([]byte("entry\n"))
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Rotate())
+	}
+	assert.NoError(t, writer.Close())
+
+	assert.Len(t, rotatedFiles(t, dir), 2)
+}
+
+func TestRotatingWriterMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	old := filepath.Join(dir, "app-2000-01-01T00-00-00.000000000.log.gz")
+	assert.NoError(t, os.WriteFile(old, []byte("old"), 0o644))
+	oldTime := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(old, oldTime, oldTime))
+	unrelated := filepath.Join(dir, "app-notes.log")
+	assert.NoError(t, os.WriteFile(unrelated, []byte("keep"), 0o644))
+	assert.NoError(t, os.Chtimes(unrelated, oldTime, oldTime))
+
+	writer, err := blackbox.NewRotatingWriter(path)
+	assert.NoError(t, err)
+	writer.SetMaxAge(24 * time.Hour)
+
+	_, err = writer.Write([]byte("entry\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Rotate())
+	assert.NoError(t, writer.Close())
+
+	rotated := rotatedFiles(t, dir)
+	assert.Len(t, rotated, 2)
+	assert.NotContains(t, rotated, filepath.Base(old))
+	assert.Contains(t, rotated, "app-notes.log")
+}
+
+func TestRotatingWriterReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	writer, err := blackbox.NewRotatingWriter(path)
+	assert.NoError(t, err)
+	writer.SetSyncPolicy(blackbox.SyncAlways)
+
+	_, err = writer.Write([]byte("first\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, os.Rename(path, path+".1"))
+	assert.NoError(t, writer.Reopen())
+	_, err = writer.Write([]byte("second\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	moved, err := os.ReadFile(path + ".1")
+	assert.NoError(t, err)
+	assert.Equal(t, "first\n", string(moved))
+
+	current, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "second\n", string(current))
+
+	_, err = writer.Write([]byte("closed\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestRotatingWriterJSONTarget(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	writer, err := blackbox.NewRotatingWriter(path)
+	assert.NoError(t, err)
+
+	logger := blackbox.New()
+	logger.AddTarget(blackbox.NewJSONTarget(writer, writer).ShowTimestamp(false))
+	logger.Info("Message")
+	assert.NoError(t, writer.Close())
+
+	current, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(current), `"Message"`)
+}
@@ -0,0 +1,148 @@
+package blackbox
+
+import (
+	"sync"
+	"time"
+)
+
+// Scope is a logger that holds back entries below a threshold level until it
+// knows whether the unit of work it covers, such as a single HTTP request, has
+// failed. If an Error level entry or above is logged through the scope, or Fail
+// is called, the held entries are written to the targets in the order they
+// were logged. If the scope ends without failing, they are discarded.
+//
+// A scope holds at most DefaultScopeMaxEntries entries unless changed with
+// SetMaxEntries. Once full, the oldest held entry is dropped to make room for
+// each new one, so a long running unit of work can not grow without bound.
+//
+// Scope embeds a Logger, so it can be used anywhere a logger is expected.
+// Sub loggers created from it with WithCtx share the scope's buffer.
+type Scope struct {
+	*Logger
+}
+
+// DefaultScopeMaxEntries is the number of entries a scope holds by default.
+const DefaultScopeMaxEntries = 1000
+
+type scope struct {
+	threshold  Level
+	maxEntries int
+	parent     *scope
+	entries    []scopeEntry
+	failed     bool
+	ended      bool
+	lock       sync.Mutex
+}
+
+// scopeEntry is an entry passing through a scope. If at is zero the targets
+// use the time the entry is written. Entries that are held are given the time
+// they were logged, so they keep it once released.
+type scopeEntry struct {
+	at        time.Time
+	targetSet *targetSet
+	loggerID  string
+	level     Level
+	values    []any
+	context   Ctx
+	fields    []Field
+	pc        []uintptr
+}
+
+// Scope creates a sub logger that holds back entries below the threshold
+// level until the scope fails or ends. Note that entries must still pass the
+// logger's level, and each target's level once released, so both should be
+// set low enough for the held entries to be seen.
+func (l *Logger) Scope(threshold Level) *Scope {
+	return &Scope{
+		Logger: &Logger{
+			id:         l.id,
+			level:      l.level,
+			context:    l.context,
+			fields:     l.fields,
+			targetSet:  l.targetSet,
+			extractors: l.extractors,
+			scope: &scope{
+				threshold:  threshold,
+				maxEntries: DefaultScopeMaxEntries,
+				parent:     l.scope,
+			},
+		},
+	}
+}
+
+// SetMaxEntries sets the maximum number of entries the scope holds. Once the
+// limit is reached the oldest held entry is dropped for each new one. A limit
+// of zero or less removes the cap.
+func (s *Scope) SetMaxEntries(max int) *Scope {
+	s.scope.lock.Lock()
+	s.scope.maxEntries = max
+	if max > 0 && len(s.scope.entries) > max {
+		s.scope.entries = s.scope.entries[len(s.scope.entries)-max:]
+	}
+	s.scope.lock.Unlock()
+	return s
+}
+
+// Fail marks the scope as failed. Any entries held by the scope are written to
+// the targets, and entries logged afterwards are written immediately.
+func (s *Scope) Fail() {
+	s.scope.lock.Lock()
+	s.scope.failed = true
+	s.scope.flush()
+	s.scope.lock.Unlock()
+}
+
+// Failed returns true if the scope has failed.
+func (s *Scope) Failed() bool {
+	s.scope.lock.Lock()
+	defer s.scope.lock.Unlock()
+	return s.scope.failed
+}
+
+// End ends the scope. If the scope has not failed, any entries it holds are
+// discarded. Entries logged after the scope has ended are written immediately.
+func (s *Scope) End() {
+	s.scope.lock.Lock()
+	s.scope.ended = true
+	s.scope.entries = nil
+	s.scope.lock.Unlock()
+}
+
+func (s *scope) log(entry scopeEntry) {
+	s.lock.Lock()
+	if !s.failed && !s.ended {
+		if entry.level < s.threshold {
+			if s.maxEntries > 0 && len(s.entries) >= s.maxEntries {
+				s.entries[0] = scopeEntry{}
+				s.entries = s.entries[1:]
+			}
+			if entry.at.IsZero() {
+				entry.at = time.Now()
+			}
+			s.entries = append(s.entries, entry)
+			s.lock.Unlock()
+			return
+		}
+		if entry.level >= Error {
+			s.failed = true
+			s.flush()
+		}
+	}
+	s.lock.Unlock()
+	s.emit(entry)
+}
+
+func (s *scope) flush() {
+	for _, entry := range s.entries {
+		s.emit(entry)
+	}
+	s.entries = nil
+}
+
+func (s *scope) emit(entry scopeEntry) {
+	if s.parent != nil {
+		s.parent.log(entry)
+		return
+	}
+	entry.targetSet.log(entry.at, entry.loggerID, entry.level, entry.values, entry.context, entry.fields, entry.pc)
+}
@@ -0,0 +1,143 @@
+package blackbox_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopeDiscardsOnEnd(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	scope := logger.Scope(blackbox.Info)
+	scope.Debug("Held")
+	scope.Info("Passed")
+	scope.End()
+
+	logged := testTarget.AllLogged()
+	assert.Len(t, logged, 1)
+	assert.Equal(t, "Passed", logged[0].Values[0])
+}
+
+func TestScopeFlushesOnError(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	scope := logger.Scope(blackbox.Info)
+	scope.Trace("One")
+	scope.Info("Two")
+	scope.WithCtx(blackbox.Ctx{"key": "value"}).Debug("Three")
+
+	assert.Len(t, testTarget.AllLogged(), 1)
+
+	scope.Error("Four")
+	scope.Debug("Five")
+	scope.End()
+
+	logged := testTarget.AllLogged()
+	assert.Len(t, logged, 5)
+	assert.Equal(t, "Two", logged[0].Values[0])
+	assert.Equal(t, "One", logged[1].Values[0])
+	assert.Equal(t, "Three", logged[2].Values[0])
+	assert.Equal(t, blackbox.Ctx{"key": "value"}, logged[2].Context)
+	assert.Contains(t, logged[2].Source.Function, "TestScopeFlushesOnError")
+	assert.Equal(t, "Four", logged[3].Values[0])
+	assert.Equal(t, "Five", logged[4].Values[0])
+	assert.True(t, scope.Failed())
+}
+
+func TestScopeFail(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	scope := logger.Scope(blackbox.Info)
+	scope.Debug("Held")
+
+	assert.Empty(t, testTarget.AllLogged())
+
+	scope.Fail()
+
+	logged, ok := testTarget.LastLogged()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Held", logged.Values[0])
+}
+
+func TestNestedScope(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	outer := logger.Scope(blackbox.Info)
+	outer.Debug("Outer")
+
+	inner := outer.Scope(blackbox.Info)
+	inner.Debug("Inner")
+	inner.Fail()
+
+	assert.Empty(t, testTarget.AllLogged())
+
+	outer.Fail()
+
+	logged := testTarget.AllLogged()
+	assert.Len(t, logged, 2)
+	assert.Equal(t, "Outer", logged[0].Values[0])
+	assert.Equal(t, "Inner", logged[1].Values[0])
+}
+
+func TestScopeMaxEntries(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	scope := logger.Scope(blackbox.Info).SetMaxEntries(2)
+	scope.Debug("One")
+	scope.Debug("Two")
+	scope.Debug("Three")
+	scope.Fail()
+
+	logged := testTarget.AllLogged()
+	assert.Len(t, logged, 2)
+	assert.Equal(t, "Two", logged[0].Values[0])
+	assert.Equal(t, "Three", logged[1].Values[0])
+}
+
+// timedTarget records the time passed to LogAt for each entry.
+type timedTarget struct {
+	lock  sync.Mutex
+	times []time.Time
+}
+
+func (t *timedTarget) Log(loggerID string, level blackbox.Level, values []any, context blackbox.Ctx, getSource func() *blackbox.Source) {
+	t.LogAt(time.Time{}, loggerID, level, values, context, getSource)
+}
+
+func (t *timedTarget) LogAt(at time.Time, loggerID string, level blackbox.Level, values []any, context blackbox.Ctx, getSource func() *blackbox.Source) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.times = append(t.times, at)
+	return nil
+}
+
+func TestScopeKeepsHeldEntryTimes(t *testing.T) {
+	logger := blackbox.New()
+	target := &timedTarget{}
+	logger.AddTarget(target)
+
+	scope := logger.Scope(blackbox.Info)
+	before := time.Now()
+	scope.Debug("Held")
+	time.Sleep(20 * time.Millisecond)
+	failedAt := time.Now()
+	scope.Fail()
+
+	assert.Len(t, target.times, 1)
+	assert.False(t, target.times[0].Before(before))
+	assert.True(t, target.times[0].Before(failedAt))
+}
@@ -0,0 +1,212 @@
+package blackbox
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink is a destination for encoded entries, such as a writer, a file or a
+// socket. Sinks are combined with an Encoder by StreamTarget. The level of
+// the entry is passed along with it so that sinks may route entries by level.
+// Write may be called from multiple goroutines at once.
+//
+// A sink may also implement Flusher or Closer, in which case StreamTarget
+// flushes or closes it when the logger is flushed or closed.
+type Sink interface {
+	Write(level Level, entry []byte) error
+}
+
+// WriterSink is a Sink that writes every entry to an io.Writer. Writes are
+// made one at a time, so the writer does not need to be safe for concurrent
+// use.
+type WriterSink struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+var _ Sink = &WriterSink{}
+
+// NewWriterSink creates a WriterSink that writes to writer. The writer is not
+// closed by the sink.
+func NewWriterSink(writer io.Writer) *WriterSink {
+	return &WriterSink{writer: writer}
+}
+
+// Write writes the entry to the writer.
+func (w *WriterSink) Write(level Level, entry []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, err := w.writer.Write(entry)
+	return err
+}
+
+// SplitSink is a Sink that writes entries to one of two writers depending on
+// their level. By default, entries at Warn and above go to the error writer,
+// and all other entries go to the output writer. The split can be moved with
+// SetErrorLevel, or replaced entirely with SetRouter. It is the sink used by
+// PrettyTarget, JSONTarget and LogfmtTarget. As with WriterSink, writes are
+// made one at a time.
+type SplitSink struct {
+	outWriter  io.Writer
+	errWriter  io.Writer
+	errorLevel Level
+	router     func(level Level) io.Writer
+	lock       sync.Mutex
+}
+
+var _ Sink = &SplitSink{}
+
+// NewSplitSink creates a SplitSink writing to outWriter and errWriter, which
+// are usually os.Stdout and os.Stderr.
+func NewSplitSink(outWriter io.Writer, errWriter io.Writer) *SplitSink {
+	return &SplitSink{
+		outWriter:  outWriter,
+		errWriter:  errWriter,
+		errorLevel: Warn,
+	}
+}
+
+// SetErrorLevel sets the minimum level of the entries written to the error
+// writer. The default is Warn.
+func (s *SplitSink) SetErrorLevel(level Level) *SplitSink {
+	s.lock.Lock()
+	s.errorLevel = level
+	s.lock.Unlock()
+	return s
+}
+
+// SetRouter sets a function that picks the writer for each entry from its
+// level, in place of the output and error writers. Entries for which the
+// router returns nil are discarded. Passing nil restores the split between
+// the output and error writers.
+func (s *SplitSink) SetRouter(router func(level Level) io.Writer) *SplitSink {
+	s.lock.Lock()
+	s.router = router
+	s.lock.Unlock()
+	return s
+}
+
+// Write writes the entry to the writer for its level.
+func (s *SplitSink) Write(level Level, entry []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var writer io.Writer
+	if s.router != nil {
+		writer = s.router(level)
+	} else if level >= s.errorLevel {
+		writer = s.errWriter
+	} else {
+		writer = s.outWriter
+	}
+	if writer == nil {
+		return nil
+	}
+	_, err := writer.Write(entry)
+	return err
+}
+
+// RouteByLevel returns a router for SplitSink.SetRouter, and the SetRouter
+// methods of the targets, that writes entries to the writer given for their
+// level in writers. Entries at levels without a writer go to fallback, which
+// may be nil to discard them.
+func RouteByLevel(writers map[Level]io.Writer, fallback io.Writer) func(level Level) io.Writer {
+	routes := make(map[Level]io.Writer, len(writers))
+	for level, writer := range writers {
+		routes[level] = writer
+	}
+	return func(level Level) io.Writer {
+		if writer, ok := routes[level]; ok {
+			return writer
+		}
+		return fallback
+	}
+}
+
+// FileSink is a Sink that appends entries to a file. For files that should be
+// rotated, use a WriterSink with a RotatingWriter instead.
+type FileSink struct {
+	file *os.File
+}
+
+var _ Sink = &FileSink{}
+var _ Flusher = &FileSink{}
+var _ Closer = &FileSink{}
+
+// NewFileSink opens the file at path for appending, creating it if it does
+// not exist.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+// Write appends the entry to the file.
+func (f *FileSink) Write(level Level, entry []byte) error {
+	_, err := f.file.Write(entry)
+	return err
+}
+
+// Flush syncs the file to disk.
+func (f *FileSink) Flush(ctx context.Context) error {
+	return f.file.Sync()
+}
+
+// Close syncs and closes the file.
+func (f *FileSink) Close(ctx context.Context) error {
+	f.file.Sync()
+	return f.file.Close()
+}
+
+// NetworkSink is a Sink that writes entries to a network connection. The
+// connection is made when the first entry is written, and is remade if it
+// fails. Networks are those accepted by net.Dial, as well as tls.
+type NetworkSink struct {
+	conn *reconnectingConn
+}
+
+var _ Sink = &NetworkSink{}
+var _ Closer = &NetworkSink{}
+
+// NewNetworkSink creates a NetworkSink that connects to address on network,
+// such as tcp, udp, unix or tls.
+func NewNetworkSink(network string, address string) *NetworkSink {
+	return &NetworkSink{conn: newReconnectingConn(network, address)}
+}
+
+// SetTLSConfig sets the TLS configuration used with the tls network.
+func (n *NetworkSink) SetTLSConfig(config *tls.Config) *NetworkSink {
+	n.conn.setTLSConfig(config)
+	return n
+}
+
+// SetTimeout sets how long connecting, and writing each entry, may take
+// before failing. The default is 5 seconds.
+func (n *NetworkSink) SetTimeout(timeout time.Duration) *NetworkSink {
+	n.conn.setTimeout(timeout)
+	return n
+}
+
+// SetBackoff sets how long the sink waits before reconnecting after a failed
+// connection attempt. The wait starts at min and doubles with each failed
+// attempt up to max. The defaults are 100 milliseconds and 30 seconds.
+func (n *NetworkSink) SetBackoff(min time.Duration, max time.Duration) *NetworkSink {
+	n.conn.setBackoff(min, max)
+	return n
+}
+
+// Write writes the entry to the connection.
+func (n *NetworkSink) Write(level Level, entry []byte) error {
+	return n.conn.write(entry)
+}
+
+// Close closes the connection.
+func (n *NetworkSink) Close(ctx context.Context) error {
+	return n.conn.close()
+}
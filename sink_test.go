@@ -0,0 +1,106 @@
+package blackbox_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitSink(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	sink := blackbox.NewSplitSink(outBuf, errBuf)
+
+	assert.NoError(t, sink.Write(blackbox.Info, []byte("info\n")))
+	assert.NoError(t, sink.Write(blackbox.Warn, []byte("warn\n")))
+	assert.NoError(t, sink.Write(blackbox.Error, []byte("error\n")))
+
+	assert.Equal(t, "info\n", outBuf.String())
+	assert.Equal(t, "warn\nerror\n", errBuf.String())
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte("existing\n"), 0o644))
+
+	sink, err := blackbox.NewFileSink(path)
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Write(blackbox.Info, []byte("appended\n")))
+	assert.NoError(t, sink.Close(context.Background()))
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "existing\nappended\n", string(contents))
+}
+
+func TestNetworkSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	encoder := blackbox.NewJSONEncoder().ShowTimestamp(false)
+	sink := blackbox.NewNetworkSink("tcp", listener.Addr().String())
+	streamTarget := blackbox.NewStreamTarget(encoder, sink)
+
+	streamTarget.Log("AAA-AAA", blackbox.Info, []any{"First"}, nil, nil)
+	streamTarget.Log("AAA-AAA", blackbox.Info, []any{"Second"}, nil, nil)
+
+	assert.Equal(t, `{"context":null,"level":"info","message":"First"}`, <-lines)
+	assert.Equal(t, `{"context":null,"level":"info","message":"Second"}`, <-lines)
+	assert.NoError(t, streamTarget.Close(context.Background()))
+}
+
+func TestSplitSinkSetErrorLevel(t *testing.T) {
+	outBuf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	sink := blackbox.NewSplitSink(outBuf, errBuf).SetErrorLevel(blackbox.Error)
+
+	assert.NoError(t, sink.Write(blackbox.Warn, []byte("warn\n")))
+	assert.NoError(t, sink.Write(blackbox.Error, []byte("error\n")))
+
+	assert.Equal(t, "warn\n", outBuf.String())
+	assert.Equal(t, "error\n", errBuf.String())
+}
+
+func TestSplitSinkRouteByLevel(t *testing.T) {
+	debugBuf := new(bytes.Buffer)
+	errorBuf := new(bytes.Buffer)
+	fallbackBuf := new(bytes.Buffer)
+	writers := map[blackbox.Level]io.Writer{
+		blackbox.Debug: debugBuf,
+		blackbox.Error: errorBuf,
+		blackbox.Trace: nil,
+	}
+	sink := blackbox.NewSplitSink(new(bytes.Buffer), new(bytes.Buffer)).
+		SetRouter(blackbox.RouteByLevel(writers, fallbackBuf))
+
+	assert.NoError(t, sink.Write(blackbox.Trace, []byte("trace\n")))
+	assert.NoError(t, sink.Write(blackbox.Debug, []byte("debug\n")))
+	assert.NoError(t, sink.Write(blackbox.Info, []byte("info\n")))
+	assert.NoError(t, sink.Write(blackbox.Error, []byte("error\n")))
+
+	assert.Equal(t, "debug\n", debugBuf.String())
+	assert.Equal(t, "error\n", errorBuf.String())
+	assert.Equal(t, "info\n", fallbackBuf.String())
+}
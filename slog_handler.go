@@ -0,0 +1,167 @@
+package blackbox
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler is a slog.Handler that forwards records to the targets of a
+// blackbox Logger. It allows code written against log/slog to write through
+// the same targets, context and source handling as the rest of blackbox.
+type SlogHandler struct {
+	logger  *Logger
+	groups  []string
+	context Ctx
+}
+
+var _ slog.Handler = &SlogHandler{}
+
+// NewSlogHandler creates a SlogHandler that writes to the targets of the
+// given logger. The logger's context is included with every record, and the
+// logger's level is used to decide which records are enabled.
+func NewSlogHandler(logger *Logger) *SlogHandler {
+	return &SlogHandler{
+		logger:  logger,
+		context: make(Ctx, 0),
+	}
+}
+
+// Enabled reports whether the handler's logger will accept records at the
+// given slog level.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.level.Enabled(levelFromSlog(level))
+}
+
+// Handle converts the record into a blackbox log entry and passes it to the
+// logger's targets. Attributes are added to the context, with groups becoming
+// nested Ctx values, along with anything the logger's context extractors pull
+// out of ctx. The time of the record is passed to targets that implement
+// TimedTarget. If the logger is a Scope, the record is held by the scope in
+// the same way as entries logged through the logger.
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+
+	logger := h.logger.withExtractedCtx(ctx)
+	context := logger.context.Extend(insertSlogAttrs(h.context, h.groups, attrs))
+
+	var pc []uintptr
+	if record.PC != 0 {
+		pc = []uintptr{record.PC}
+	}
+
+	if logger.scope != nil {
+		logger.scope.log(scopeEntry{
+			at:        record.Time,
+			targetSet: logger.targetSet,
+			loggerID:  logger.id,
+			level:     levelFromSlog(record.Level),
+			values:    []any{record.Message},
+			context:   context,
+			fields:    logger.fields,
+			pc:        pc,
+		})
+		return nil
+	}
+	logger.targetSet.log(record.Time, logger.id, levelFromSlog(record.Level), []any{record.Message}, context, logger.fields, pc)
+	return nil
+}
+
+// WithAttrs returns a new SlogHandler with the given attributes added to its
+// context.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &SlogHandler{
+		logger:  h.logger,
+		groups:  h.groups,
+		context: insertSlogAttrs(h.context, h.groups, attrs),
+	}
+}
+
+// WithGroup returns a new SlogHandler that nests all following attributes
+// under the given group name.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(groups, h.groups)
+	return &SlogHandler{
+		logger:  h.logger,
+		groups:  append(groups, name),
+		context: h.context,
+	}
+}
+
+// levelFromSlog maps a slog level to a blackbox level. It is the inverse of
+// levelToSlog, with levels between the mapped ones rounded down.
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return Trace
+	case level < SlogLevelVerbose:
+		return Debug
+	case level < slog.LevelInfo:
+		return Verbose
+	case level < slog.LevelWarn:
+		return Info
+	case level < slog.LevelError:
+		return Warn
+	case level < SlogLevelFatal:
+		return Error
+	case level < SlogLevelPanic:
+		return Fatal
+	}
+	return Panic
+}
+
+// insertSlogAttrs returns a copy of context with attrs added under the nested
+// Ctx found by following groups. The original context is left untouched.
+func insertSlogAttrs(context Ctx, groups []string, attrs []slog.Attr) Ctx {
+	newContext := context.Extend(nil)
+
+	if len(groups) != 0 {
+		group, _ := newContext[groups[0]].(Ctx)
+		groupContext := insertSlogAttrs(group, groups[1:], attrs)
+		if len(groupContext) != 0 {
+			newContext[groups[0]] = groupContext
+		}
+		return newContext
+	}
+
+	for _, attr := range attrs {
+		addSlogAttr(newContext, attr)
+	}
+
+	return newContext
+}
+
+func addSlogAttr(context Ctx, attr slog.Attr) {
+	value := attr.Value.Resolve()
+
+	if value.Kind() == slog.KindGroup {
+		groupAttrs := value.Group()
+		if len(groupAttrs) == 0 {
+			return
+		}
+		if attr.Key == "" {
+			for _, groupAttr := range groupAttrs {
+				addSlogAttr(context, groupAttr)
+			}
+			return
+		}
+		group, _ := context[attr.Key].(Ctx)
+		context[attr.Key] = insertSlogAttrs(group, nil, groupAttrs)
+		return
+	}
+
+	if attr.Key == "" {
+		return
+	}
+	context[attr.Key] = value.Any()
+}
@@ -0,0 +1,136 @@
+package blackbox_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogHandler(t *testing.T) {
+	logger := blackbox.NewWithCtx(blackbox.Ctx{"service": "api"})
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	slogger := slog.New(blackbox.NewSlogHandler(logger))
+	slogger.Warn("Message", "key", "value", "count", 3)
+
+	logged, ok := testTarget.LastLogged()
+
+	assert.Equal(t, true, ok)
+	assert.Equal(t, blackbox.Warn, logged.Level)
+	assert.Equal(t, []any{"Message"}, logged.Values)
+	assert.Equal(t, blackbox.Ctx{"service": "api", "key": "value", "count": int64(3)}, logged.Context)
+}
+
+func TestSlogHandlerLevels(t *testing.T) {
+	logger := blackbox.New()
+	logger.SetLevel(blackbox.Info)
+	handler := blackbox.NewSlogHandler(logger)
+
+	assert.False(t, handler.Enabled(context.Background(), slog.LevelDebug))
+	assert.True(t, handler.Enabled(context.Background(), slog.LevelInfo))
+	assert.True(t, handler.Enabled(context.Background(), slog.LevelError+4))
+}
+
+func TestSlogHandlerWithAttrsAndGroup(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	slogger := slog.New(blackbox.NewSlogHandler(logger)).
+		With("a", 1).
+		WithGroup("req").
+		With("id", "123").
+		WithGroup("empty")
+	slogger.Info("Message", slog.Group("user", "name", "bob"))
+
+	logged, ok := testTarget.LastLogged()
+
+	assert.Equal(t, true, ok)
+	assert.Equal(t, blackbox.Ctx{
+		"a": int64(1),
+		"req": blackbox.Ctx{
+			"id": "123",
+			"empty": blackbox.Ctx{
+				"user": blackbox.Ctx{"name": "bob"},
+			},
+		},
+	}, logged.Context)
+}
+
+func TestSlogHandlerSource(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	slog.New(blackbox.NewSlogHandler(logger)).Info("Message")
+
+	logged, ok := testTarget.LastLogged()
+
+	assert.Equal(t, true, ok)
+	assert.Contains(t, logged.Source.Function, "TestSlogHandlerSource")
+	assert.Contains(t, logged.Source.File, "slog_handler_test.go")
+}
+
+func TestSlogHandlerRunsContextExtractors(t *testing.T) {
+	logger := blackbox.New()
+	logger.AddContextExtractor(blackbox.ContextValueExtractor(requestIDKey{}, "requestID"))
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "abc")
+	slog.New(blackbox.NewSlogHandler(logger)).InfoContext(ctx, "Message", "key", "value")
+
+	logged, ok := testTarget.LastLogged()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, blackbox.Ctx{"requestID": "abc", "key": "value"}, logged.Context)
+}
+
+func TestSlogHandlerKeepsRecordTime(t *testing.T) {
+	logger := blackbox.New()
+	buffer := &bytes.Buffer{}
+	logger.AddTarget(blackbox.NewAsyncTarget(blackbox.NewLogfmtTarget(buffer, buffer).ShowLevel(false).ShowContext(false), 1))
+
+	recordTime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.Local)
+	record := slog.NewRecord(recordTime, slog.LevelInfo, "Message", 0)
+	assert.NoError(t, blackbox.NewSlogHandler(logger).Handle(context.Background(), record))
+	assert.NoError(t, logger.Close(context.Background()))
+
+	assert.Equal(t, "time="+recordTime.Format(time.RFC3339)+" msg=Message\n", buffer.String())
+}
+
+func TestSlogHandlerWithFields(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	slogger := slog.New(blackbox.NewSlogHandler(logger.WithFields(blackbox.String("service", "api"))))
+	slogger.Info("Message", "key", "value")
+
+	logged, ok := testTarget.LastLogged()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, blackbox.Ctx{"service": "api", "key": "value"}, logged.Context)
+}
+
+func TestSlogHandlerScope(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	scope := logger.Scope(blackbox.Info)
+	slogger := slog.New(blackbox.NewSlogHandler(scope.Logger))
+	slogger.Debug("Held")
+
+	assert.Empty(t, testTarget.AllLogged())
+
+	scope.Fail()
+
+	logged, ok := testTarget.LastLogged()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Held", logged.Values[0])
+}
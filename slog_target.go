@@ -0,0 +1,178 @@
+package blackbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// slog only defines the Debug, Info, Warn and Error levels. The levels below
+// are used for the blackbox levels slog lacks. They sit between (or beyond)
+// the standard slog levels so that handlers comparing levels still order them
+// correctly.
+const (
+	// SlogLevelTrace is the slog level used for Trace entries
+	SlogLevelTrace = slog.LevelDebug - 4
+	// SlogLevelVerbose is the slog level used for Verbose entries
+	SlogLevelVerbose = slog.LevelInfo - 2
+	// SlogLevelFatal is the slog level used for Fatal entries
+	SlogLevelFatal = slog.LevelError + 4
+	// SlogLevelPanic is the slog level used for Panic entries
+	SlogLevelPanic = slog.LevelError + 8
+)
+
+// SlogTarget is a Target that converts log entries into slog records and
+// passes them to a slog.Handler. This allows libraries to log with blackbox
+// while the host application controls formatting through log/slog.
+//
+// Levels are mapped as follows:
+//
+//	Trace   -> SlogLevelTrace (DEBUG-4)
+//	Debug   -> slog.LevelDebug
+//	Verbose -> SlogLevelVerbose (DEBUG+2)
+//	Info    -> slog.LevelInfo
+//	Warn    -> slog.LevelWarn
+//	Error   -> slog.LevelError
+//	Fatal   -> SlogLevelFatal (ERROR+4)
+//	Panic   -> SlogLevelPanic (ERROR+8)
+type SlogTarget struct {
+	showLoggerID atomic.Bool
+	useSource    atomic.Bool
+	level        AtomicLevel
+	handler      slog.Handler
+}
+
+var _ ErrorTarget = &SlogTarget{}
+var _ TimedTarget = &SlogTarget{}
+
+// NewSlogTarget creates a SlogTarget that writes to the given slog.Handler
+func NewSlogTarget(handler slog.Handler) *SlogTarget {
+	return &SlogTarget{
+		handler: handler,
+	}
+}
+
+// SetLevel sets the minimum log level that SlogTarget will pass to the
+// handler. Note that the handler may apply its own level as well.
+func (s *SlogTarget) SetLevel(level Level) *SlogTarget {
+	s.level.SetLevel(level)
+	return s
+}
+
+// ShowLoggerID will enable or disable the inclusion of a loggerID attribute
+// depending on the boolean value passed.
+func (s *SlogTarget) ShowLoggerID(b bool) *SlogTarget {
+	s.showLoggerID.Store(b)
+	return s
+}
+
+// UseSource enables the inclusion of a source attribute in the same shape
+// slog's built-in handlers use.
+func (s *SlogTarget) UseSource(b bool) *SlogTarget {
+	s.useSource.Store(b)
+	return s
+}
+
+// Enabled reports whether SlogTarget accepts entries at the given level.
+func (s *SlogTarget) Enabled(level Level) bool {
+	return s.level.Enabled(level)
+}
+
+// UsesSource reports whether SlogTarget includes the source of entries.
+func (s *SlogTarget) UsesSource() bool {
+	return s.useSource.Load()
+}
+
+// Log takes a Level and series of values, then passes them to the handler as
+// a slog record. Context key value pairs become record attributes. Errors
+// returned by the handler are written to stderr.
+func (s *SlogTarget) Log(loggerID string, level Level, values []any, ctx Ctx, getSource func() *Source) {
+	if err := s.TryLog(loggerID, level, values, ctx, getSource); err != nil {
+		reportTargetError(s, err)
+	}
+}
+
+// TryLog behaves the same as Log, but returns any error returned by the
+// handler.
+func (s *SlogTarget) TryLog(loggerID string, level Level, values []any, ctx Ctx, getSource func() *Source) error {
+	return s.LogAt(time.Now(), loggerID, level, values, ctx, getSource)
+}
+
+// LogAt behaves the same as TryLog, but uses the given time for the entry.
+func (s *SlogTarget) LogAt(at time.Time, loggerID string, level Level, values []any, ctx Ctx, getSource func() *Source) error {
+	if !s.level.Enabled(level) {
+		return nil
+	}
+
+	slogLevel := levelToSlog(level)
+	if !s.handler.Enabled(context.Background(), slogLevel) {
+		return nil
+	}
+
+	strValues := make([]string, 0)
+	for _, value := range values {
+		strValues = append(strValues, fmt.Sprintf("%+v", value))
+	}
+	record := slog.NewRecord(at, slogLevel, strings.Join(strValues, " "), 0)
+
+	if s.showLoggerID.Load() {
+		record.AddAttrs(slog.String("loggerID", loggerID))
+	}
+	record.AddAttrs(slogAttrsFromCtx(ctx)...)
+	if s.useSource.Load() {
+		if source := getSource(); source != nil {
+			record.AddAttrs(slog.Any(slog.SourceKey, &slog.Source{
+				Function: source.Function,
+				File:     source.File,
+				Line:     source.Line,
+			}))
+		}
+	}
+
+	return s.handler.Handle(context.Background(), record)
+}
+
+func levelToSlog(level Level) slog.Level {
+	switch level {
+	case Trace:
+		return SlogLevelTrace
+	case Debug:
+		return slog.LevelDebug
+	case Verbose:
+		return SlogLevelVerbose
+	case Info:
+		return slog.LevelInfo
+	case Warn:
+		return slog.LevelWarn
+	case Error:
+		return slog.LevelError
+	case Fatal:
+		return SlogLevelFatal
+	}
+	return SlogLevelPanic
+}
+
+func slogAttrsFromCtx(ctx Ctx) []slog.Attr {
+	keys := make([]string, 0, len(ctx))
+	for key := range ctx {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, key := range keys {
+		switch value := ctx[key].(type) {
+		case Ctx:
+			attrs = append(attrs, slog.Attr{Key: key, Value: slog.GroupValue(slogAttrsFromCtx(value)...)})
+		case map[string]any:
+			attrs = append(attrs, slog.Attr{Key: key, Value: slog.GroupValue(slogAttrsFromCtx(value)...)})
+		default:
+			attrs = append(attrs, slog.Any(key, value))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,90 @@
+package blackbox_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogTarget(t *testing.T) {
+	buf := new(bytes.Buffer)
+	slogTarget := blackbox.NewSlogTarget(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: blackbox.SlogLevelTrace}))
+
+	values := make([]any, 2)
+	values[0] = "Hello"
+	values[1] = "Test"
+
+	slogTarget.Log("AAA-AAA", blackbox.Trace, values, blackbox.Ctx{
+		"key":  "value",
+		"user": blackbox.Ctx{"name": "bob"},
+	}, nil)
+
+	var output map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+
+	assert.Equal(t, "Hello Test", output["msg"])
+	assert.Equal(t, "DEBUG-4", output["level"])
+	assert.Equal(t, "value", output["key"])
+	assert.Equal(t, map[string]any{"name": "bob"}, output["user"])
+}
+
+func TestSlogTargetLevelMapping(t *testing.T) {
+	buf := new(bytes.Buffer)
+	slogTarget := blackbox.NewSlogTarget(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: blackbox.SlogLevelTrace}))
+
+	slogTarget.Log("AAA-AAA", blackbox.Verbose, []any{"verbose"}, nil, nil)
+	slogTarget.Log("AAA-AAA", blackbox.Fatal, []any{"fatal"}, nil, nil)
+	slogTarget.Log("AAA-AAA", blackbox.Panic, []any{"panic"}, nil, nil)
+
+	assert.Regexp(t, `level=DEBUG\+2 msg=verbose`, buf.String())
+	assert.Regexp(t, `level=ERROR\+4 msg=fatal`, buf.String())
+	assert.Regexp(t, `level=ERROR\+8 msg=panic`, buf.String())
+}
+
+func TestSlogTargetSetLevel(t *testing.T) {
+	buf := new(bytes.Buffer)
+	slogTarget := blackbox.NewSlogTarget(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: blackbox.SlogLevelTrace}))
+
+	slogTarget.SetLevel(blackbox.Info)
+
+	slogTarget.Log("AAA-AAA", blackbox.Debug, []any{"Filtered Message"}, nil, nil)
+	slogTarget.Log("AAA-AAA", blackbox.Info, []any{"Hello Test"}, nil, nil)
+
+	assert.NotRegexp(t, `Filtered Message`, buf.String())
+	assert.Regexp(t, `Hello Test`, buf.String())
+}
+
+func TestSlogTargetUseSource(t *testing.T) {
+	buf := new(bytes.Buffer)
+	slogTarget := blackbox.NewSlogTarget(slog.NewTextHandler(buf, nil))
+
+	slogTarget.UseSource(true).ShowLoggerID(true)
+
+	slogTarget.Log("AAA-AAA", blackbox.Info, []any{"Hello Test"}, nil, func() *blackbox.Source {
+		return &blackbox.Source{
+			File:     "file.go",
+			Line:     123,
+			Function: "functionName",
+		}
+	})
+
+	assert.Regexp(t, `loggerID=AAA-AAA source=file\.go:123`, buf.String())
+}
+
+func TestSlogRoundTrip(t *testing.T) {
+	logger := blackbox.New()
+	testTarget := blackbox.NewTestTarget()
+	logger.AddTarget(testTarget)
+
+	slogTarget := blackbox.NewSlogTarget(blackbox.NewSlogHandler(logger))
+	slogTarget.Log("AAA-AAA", blackbox.Verbose, []any{"Hello Test"}, nil, nil)
+
+	logged, ok := testTarget.LastLogged()
+
+	assert.Equal(t, true, ok)
+	assert.Equal(t, blackbox.Verbose, logged.Level)
+}
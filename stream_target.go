@@ -0,0 +1,156 @@
+package blackbox
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Encoder formats entries, turning each into the bytes a Sink writes. blackbox
+// ships with PrettyEncoder, JSONEncoder and LogfmtEncoder. An encoder may
+// return nil to skip an entry.
+type Encoder interface {
+	Encode(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) ([]byte, error)
+}
+
+// FieldEncoder is an optional extension of Encoder for encoders that encode
+// fields directly. Encoders that do not implement it receive the fields
+// merged into the entry's context.
+type FieldEncoder interface {
+	Encoder
+	EncodeFields(loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) ([]byte, error)
+}
+
+// TimedEncoder is an optional extension of Encoder for encoders that include
+// the time of each entry. When the time an entry was created is known,
+// StreamTarget calls EncodeAt in place of Encode or EncodeFields.
+type TimedEncoder interface {
+	Encoder
+	EncodeAt(at time.Time, loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) ([]byte, error)
+}
+
+// StreamTarget is a Target that composes an Encoder with a Sink, so that any
+// format can be written to any destination. PrettyTarget, JSONTarget and
+// LogfmtTarget are each a StreamTarget writing to a SplitSink.
+type StreamTarget struct {
+	level    AtomicLevel
+	redactor atomic.Pointer[Redactor]
+	encoder  Encoder
+	sink     Sink
+}
+
+var _ ErrorTarget = &StreamTarget{}
+var _ FieldTarget = &StreamTarget{}
+var _ TimedTarget = &StreamTarget{}
+var _ Flusher = &StreamTarget{}
+var _ Closer = &StreamTarget{}
+var _ Enabler = &StreamTarget{}
+var _ SourceUser = &StreamTarget{}
+
+// NewStreamTarget creates a StreamTarget that encodes entries with encoder and
+// writes them to sink.
+func NewStreamTarget(encoder Encoder, sink Sink) *StreamTarget {
+	return &StreamTarget{
+		encoder: encoder,
+		sink:    sink,
+	}
+}
+
+// SetLevel sets the minimum log level that StreamTarget will output. Note that
+// this setting is independent of the log level set on the logger itself.
+func (s *StreamTarget) SetLevel(level Level) *StreamTarget {
+	s.level.SetLevel(level)
+	return s
+}
+
+// SetRedactor sets a Redactor used to mask secrets in values and context
+// before they are encoded. Passing nil disables redaction.
+func (s *StreamTarget) SetRedactor(redactor *Redactor) *StreamTarget {
+	s.redactor.Store(redactor)
+	return s
+}
+
+// Enabled reports whether StreamTarget accepts entries at the given level.
+func (s *StreamTarget) Enabled(level Level) bool {
+	return s.level.Enabled(level)
+}
+
+// UsesSource reports whether the encoder includes the source of entries.
+// Encoders that do not implement SourceUser are assumed to.
+func (s *StreamTarget) UsesSource() bool {
+	if sourceUser, ok := s.encoder.(SourceUser); ok {
+		return sourceUser.UsesSource()
+	}
+	return true
+}
+
+// Log encodes the entry and writes it to the sink. Errors are written to
+// stderr.
+func (s *StreamTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	if err := s.TryLog(loggerID, level, values, context, getSource); err != nil {
+		reportTargetError(s, err)
+	}
+}
+
+// TryLog behaves the same as Log, but returns any error encountered while
+// encoding or writing the entry.
+func (s *StreamTarget) TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return s.LogFields(loggerID, level, values, context, nil, getSource)
+}
+
+// LogFields behaves the same as TryLog, but also encodes the given fields.
+func (s *StreamTarget) LogFields(loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) error {
+	return s.log(time.Time{}, loggerID, level, values, context, fields, getSource)
+}
+
+// LogAt behaves the same as TryLog, but uses the given time for the entry if
+// the encoder implements TimedEncoder.
+func (s *StreamTarget) LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return s.log(at, loggerID, level, values, context, nil, getSource)
+}
+
+func (s *StreamTarget) log(at time.Time, loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) error {
+	if !s.level.Enabled(level) {
+		return nil
+	}
+
+	if redactor := s.redactor.Load(); redactor != nil {
+		values = redactor.RedactValues(values)
+		context = redactor.RedactCtx(context)
+		fields = redactor.RedactFields(fields)
+	}
+
+	var entry []byte
+	var err error
+	if timedEncoder, ok := s.encoder.(TimedEncoder); ok && !at.IsZero() {
+		entry, err = timedEncoder.EncodeAt(at, loggerID, level, values, context, fields, getSource)
+	} else if fieldEncoder, ok := s.encoder.(FieldEncoder); ok && len(fields) != 0 {
+		entry, err = fieldEncoder.EncodeFields(loggerID, level, values, context, fields, getSource)
+	} else {
+		if len(fields) != 0 {
+			context = context.Extend(fieldsCtx(fields))
+		}
+		entry, err = s.encoder.Encode(loggerID, level, values, context, getSource)
+	}
+	if err != nil || entry == nil {
+		return err
+	}
+	return s.sink.Write(level, entry)
+}
+
+// Flush flushes the sink if it implements Flusher.
+func (s *StreamTarget) Flush(ctx context.Context) error {
+	if flusher, ok := s.sink.(Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return nil
+}
+
+// Close closes the sink if it implements Closer, otherwise it is flushed if
+// it implements Flusher.
+func (s *StreamTarget) Close(ctx context.Context) error {
+	if closer, ok := s.sink.(Closer); ok {
+		return closer.Close(ctx)
+	}
+	return s.Flush(ctx)
+}
@@ -0,0 +1,100 @@
+package blackbox_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+type bufferSink struct {
+	entries []string
+	levels  []blackbox.Level
+	closed  bool
+}
+
+func (b *bufferSink) Write(level blackbox.Level, entry []byte) error {
+	b.entries = append(b.entries, string(entry))
+	b.levels = append(b.levels, level)
+	return nil
+}
+
+func (b *bufferSink) Close(ctx context.Context) error {
+	b.closed = true
+	return nil
+}
+
+type failingEncoder struct{}
+
+func (failingEncoder) Encode(loggerID string, level blackbox.Level, values []any, context blackbox.Ctx, getSource func() *blackbox.Source) ([]byte, error) {
+	return nil, errors.New("encode failed")
+}
+
+func TestStreamTarget(t *testing.T) {
+	sink := &bufferSink{}
+	encoder := blackbox.NewLogfmtEncoder().ShowTimestamp(false)
+	streamTarget := blackbox.NewStreamTarget(encoder, sink).SetLevel(blackbox.Info)
+
+	streamTarget.Log("AAA-AAA", blackbox.Debug, []any{"Dropped"}, nil, nil)
+	streamTarget.Log("AAA-AAA", blackbox.Warn, []any{"Hello"}, blackbox.Ctx{"user": "bob"}, nil)
+
+	assert.Equal(t, []string{"level=warn msg=Hello user=bob\n"}, sink.entries)
+	assert.Equal(t, []blackbox.Level{blackbox.Warn}, sink.levels)
+}
+
+func TestStreamTargetEncoders(t *testing.T) {
+	sink := &bufferSink{}
+
+	blackbox.NewStreamTarget(blackbox.NewJSONEncoder().ShowTimestamp(false).ShowLoggerID(true), sink).
+		Log("AAA-AAA", blackbox.Info, []any{"Hello"}, blackbox.Ctx{"user": "bob"}, nil)
+	blackbox.NewStreamTarget(blackbox.NewPrettyEncoder().ShowTimestamp(false).UseColor(false), sink).
+		Log("AAA-AAA", blackbox.Info, []any{"Hello"}, blackbox.Ctx{"user": "bob"}, nil)
+
+	assert.Equal(t, []string{
+		`{"context":{"user":"bob"},"level":"info","loggerID":"AAA-AAA","message":"Hello"}` + "\n",
+		"info    Hello user=bob\n",
+	}, sink.entries)
+}
+
+func TestStreamTargetRedactor(t *testing.T) {
+	sink := &bufferSink{}
+	encoder := blackbox.NewLogfmtEncoder().ShowTimestamp(false).ShowLevel(false)
+	streamTarget := blackbox.NewStreamTarget(encoder, sink).SetRedactor(blackbox.NewRedactor().RedactKeys("password"))
+
+	streamTarget.Log("AAA-AAA", blackbox.Info, []any{"Login"}, blackbox.Ctx{"password": "hunter2"}, nil)
+
+	assert.NotContains(t, sink.entries[0], "hunter2")
+}
+
+func TestStreamTargetErrors(t *testing.T) {
+	streamTarget := blackbox.NewStreamTarget(failingEncoder{}, &bufferSink{})
+	assert.EqualError(t, streamTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil), "encode failed")
+
+	streamTarget = blackbox.NewStreamTarget(blackbox.NewJSONEncoder(), blackbox.NewWriterSink(&errWriter{}))
+	assert.Error(t, streamTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Message"}, nil, nil))
+}
+
+func TestStreamTargetClose(t *testing.T) {
+	sink := &bufferSink{}
+	streamTarget := blackbox.NewStreamTarget(blackbox.NewJSONEncoder(), sink)
+
+	logger := blackbox.New()
+	logger.AddTarget(streamTarget)
+	assert.NoError(t, logger.Close(context.Background()))
+
+	assert.True(t, sink.closed)
+}
+
+func TestStreamTargetWriterSink(t *testing.T) {
+	buf := new(bytes.Buffer)
+	encoder := blackbox.NewLogfmtEncoder().ShowTimestamp(false)
+	streamTarget := blackbox.NewStreamTarget(encoder, blackbox.NewWriterSink(buf))
+
+	streamTarget.Log("AAA-AAA", blackbox.Info, []any{"First"}, nil, nil)
+	streamTarget.Log("AAA-AAA", blackbox.Error, []any{"Second"}, nil, nil)
+
+	assert.Equal(t, "level=info msg=First\nlevel=error msg=Second\n", buf.String())
+}
@@ -0,0 +1,361 @@
+package blackbox
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// SyslogFacility is the syslog facility a SyslogTarget reports entries under.
+type SyslogFacility int
+
+const (
+	// SyslogKern is the kernel facility
+	SyslogKern SyslogFacility = 0
+	// SyslogUser is the user-level facility
+	SyslogUser SyslogFacility = 1
+	// SyslogDaemon is the system daemon facility
+	SyslogDaemon SyslogFacility = 3
+	// SyslogAuth is the security and authorization facility
+	SyslogAuth SyslogFacility = 4
+	// SyslogLocal0 is the first of the facilities reserved for local use
+	SyslogLocal0 SyslogFacility = 16
+	// SyslogLocal1 is a facility reserved for local use
+	SyslogLocal1 SyslogFacility = 17
+	// SyslogLocal2 is a facility reserved for local use
+	SyslogLocal2 SyslogFacility = 18
+	// SyslogLocal3 is a facility reserved for local use
+	SyslogLocal3 SyslogFacility = 19
+	// SyslogLocal4 is a facility reserved for local use
+	SyslogLocal4 SyslogFacility = 20
+	// SyslogLocal5 is a facility reserved for local use
+	SyslogLocal5 SyslogFacility = 21
+	// SyslogLocal6 is a facility reserved for local use
+	SyslogLocal6 SyslogFacility = 22
+	// SyslogLocal7 is the last of the facilities reserved for local use
+	SyslogLocal7 SyslogFacility = 23
+)
+
+const (
+	// DefaultSyslogStructuredDataID is the SD-ID of the structured data
+	// element holding the context of each entry.
+	DefaultSyslogStructuredDataID = "ctx@32473"
+
+	syslogSourceStructuredDataID = "source@32473"
+	syslogTimestampFormat        = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// SyslogTarget is a Target that sends entries to a syslog server, such as
+// rsyslog or syslog-ng, as RFC 5424 messages. The context of each entry is
+// sent as structured data.
+//
+// Levels are mapped to syslog severities as follows:
+//
+//	Trace   -> debug (7)
+//	Debug   -> debug (7)
+//	Verbose -> informational (6)
+//	Info    -> informational (6)
+//	Warn    -> warning (4)
+//	Error   -> error (3)
+//	Fatal   -> critical (2)
+//	Panic   -> alert (1)
+//
+// The connection is made when the first entry is logged. If the connection
+// fails it is reestablished, backing off between failed attempts. Entries
+// logged while the target is backing off are dropped and reported as errors.
+type SyslogTarget struct {
+	network          string
+	conn             *reconnectingConn
+	facility         atomic.Int64
+	hostname         atomic.Pointer[string]
+	appName          atomic.Pointer[string]
+	procID           string
+	structuredDataID atomic.Pointer[string]
+	showLoggerID     atomic.Bool
+	useSource        atomic.Bool
+	level            AtomicLevel
+}
+
+var _ ErrorTarget = &SyslogTarget{}
+var _ TimedTarget = &SyslogTarget{}
+var _ Closer = &SyslogTarget{}
+
+// NewSyslogTarget creates a SyslogTarget that sends entries to the syslog
+// server at address. The network may be "udp", "tcp", "tls", "unix" or
+// "unixgram". Stream networks frame messages with octet counting as described
+// in RFC 6587, while datagram networks send one message per datagram.
+func NewSyslogTarget(network string, address string) *SyslogTarget {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+	}
+	target := &SyslogTarget{
+		network: network,
+		conn:    newReconnectingConn(network, address),
+		procID:  strconv.Itoa(os.Getpid()),
+	}
+	target.SetFacility(SyslogUser)
+	target.SetHostname(hostname)
+	target.SetAppName(filepath.Base(os.Args[0]))
+	target.SetStructuredDataID(DefaultSyslogStructuredDataID)
+	return target
+}
+
+// SetLevel sets the minimum log level that SyslogTarget will send. Note that
+// this setting is independent of the log level set on the logger itself.
+func (s *SyslogTarget) SetLevel(level Level) *SyslogTarget {
+	s.level.SetLevel(level)
+	return s
+}
+
+// SetFacility sets the facility entries are reported under. The default is
+// SyslogUser.
+func (s *SyslogTarget) SetFacility(facility SyslogFacility) *SyslogTarget {
+	s.facility.Store(int64(facility))
+	return s
+}
+
+// SetHostname sets the HOSTNAME field of each message. The default is the
+// hostname reported by the operating system.
+func (s *SyslogTarget) SetHostname(hostname string) *SyslogTarget {
+	s.hostname.Store(&hostname)
+	return s
+}
+
+// SetAppName sets the APP-NAME field of each message. The default is the name
+// of the running program.
+func (s *SyslogTarget) SetAppName(appName string) *SyslogTarget {
+	s.appName.Store(&appName)
+	return s
+}
+
+// SetStructuredDataID sets the SD-ID of the structured data element holding
+// the context of each entry. The default is DefaultSyslogStructuredDataID.
+func (s *SyslogTarget) SetStructuredDataID(id string) *SyslogTarget {
+	s.structuredDataID.Store(&id)
+	return s
+}
+
+// SetTLSConfig sets the TLS configuration used by the "tls" network.
+func (s *SyslogTarget) SetTLSConfig(config *tls.Config) *SyslogTarget {
+	s.conn.setTLSConfig(config)
+	return s
+}
+
+// SetTimeout sets how long connecting to the server, and writing each
+// message, may take before failing. The default is 5 seconds.
+func (s *SyslogTarget) SetTimeout(timeout time.Duration) *SyslogTarget {
+	s.conn.setTimeout(timeout)
+	return s
+}
+
+// SetBackoff sets how long the target waits before reconnecting after a
+// failed connection attempt. The wait starts at min and doubles with each
+// failed attempt up to max. The defaults are 100 milliseconds and 30 seconds.
+func (s *SyslogTarget) SetBackoff(min time.Duration, max time.Duration) *SyslogTarget {
+	s.conn.setBackoff(min, max)
+	return s
+}
+
+// ShowLoggerID will enable or disable the inclusion of the logger ID as the
+// MSGID field of each message depending on the boolean value passed.
+func (s *SyslogTarget) ShowLoggerID(b bool) *SyslogTarget {
+	s.showLoggerID.Store(b)
+	return s
+}
+
+// UseSource enables the inclusion of a source structured data element.
+func (s *SyslogTarget) UseSource(b bool) *SyslogTarget {
+	s.useSource.Store(b)
+	return s
+}
+
+// Enabled reports whether SyslogTarget accepts entries at the given level.
+func (s *SyslogTarget) Enabled(level Level) bool {
+	return s.level.Enabled(level)
+}
+
+// UsesSource reports whether SyslogTarget includes the source of entries.
+func (s *SyslogTarget) UsesSource() bool {
+	return s.useSource.Load()
+}
+
+// Log takes a Level and series of values, then sends them to the syslog
+// server. Errors are written to stderr.
+func (s *SyslogTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	if err := s.TryLog(loggerID, level, values, context, getSource); err != nil {
+		reportTargetError(s, err)
+	}
+}
+
+// TryLog behaves the same as Log, but returns any error encountered while
+// connecting to the server or sending the message.
+func (s *SyslogTarget) TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	return s.LogAt(time.Now(), loggerID, level, values, context, getSource)
+}
+
+// LogAt behaves the same as TryLog, but uses the given time for the entry.
+func (s *SyslogTarget) LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	if !s.level.Enabled(level) {
+		return nil
+	}
+
+	var source *Source
+	if s.useSource.Load() {
+		source = getSource()
+	}
+	message := s.formatMessage(at, loggerID, level, values, context, source)
+
+	if s.isStream() {
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+	return s.conn.write([]byte(message))
+}
+
+// Close closes the connection to the server.
+func (s *SyslogTarget) Close(ctx context.Context) error {
+	return s.conn.close()
+}
+
+func (s *SyslogTarget) isStream() bool {
+	return s.network == "tcp" || s.network == "tcp4" || s.network == "tcp6" || s.network == "tls" || s.network == "unix"
+}
+
+func (s *SyslogTarget) formatMessage(now time.Time, loggerID string, level Level, values []any, context Ctx, source *Source) string {
+	var builder strings.Builder
+
+	priority := int(s.facility.Load())*8 + syslogSeverity(level)
+	builder.WriteString("<" + strconv.Itoa(priority) + ">1 ")
+	builder.WriteString(now.Format(syslogTimestampFormat) + " ")
+	builder.WriteString(syslogHeaderField(*s.hostname.Load(), 255) + " ")
+	builder.WriteString(syslogHeaderField(*s.appName.Load(), 48) + " ")
+	builder.WriteString(syslogHeaderField(s.procID, 128) + " ")
+	if s.showLoggerID.Load() {
+		builder.WriteString(syslogHeaderField(loggerID, 32) + " ")
+	} else {
+		builder.WriteString("- ")
+	}
+
+	hasStructuredData := false
+	if len(context) != 0 {
+		params := make(map[string]string)
+		flattenSyslogCtx(params, "", context)
+		writeSyslogElement(&builder, *s.structuredDataID.Load(), params)
+		hasStructuredData = true
+	}
+	if source != nil {
+		writeSyslogElement(&builder, syslogSourceStructuredDataID, map[string]string{
+			"file":     source.File,
+			"line":     strconv.Itoa(source.Line),
+			"function": source.Function,
+		})
+		hasStructuredData = true
+	}
+	if !hasStructuredData {
+		builder.WriteString("-")
+	}
+
+	strValues := make([]string, 0)
+	for _, value := range values {
+		strValues = append(strValues, fmt.Sprintf("%+v", value))
+	}
+	if message := strings.Join(strValues, " "); message != "" {
+		builder.WriteString(" " + message)
+	}
+
+	return builder.String()
+}
+
+// syslogSeverity returns the syslog severity matching a blackbox level.
+func syslogSeverity(level Level) int {
+	switch level {
+	case Trace, Debug:
+		return 7
+	case Verbose, Info:
+		return 6
+	case Warn:
+		return 4
+	case Error:
+		return 3
+	case Fatal:
+		return 2
+	}
+	return 1
+}
+
+// syslogHeaderField makes value safe for use as a header field, which must be
+// printable ASCII without spaces. Empty values are replaced with the nil
+// value, a hyphen.
+func syslogHeaderField(value string, maxLength int) string {
+	field := []byte(value)
+	for i, c := range field {
+		if c < 33 || c > 126 {
+			field[i] = '_'
+		}
+	}
+	if len(field) > maxLength {
+		field = field[:maxLength]
+	}
+	if len(field) == 0 {
+		return "-"
+	}
+	return string(field)
+}
+
+// syslogParamName makes key safe for use as a structured data parameter name,
+// which must be printable ASCII without spaces, '=', ']' or '"'.
+func syslogParamName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	if len(name) == 0 {
+		return "_"
+	}
+	return string(name)
+}
+
+// flattenSyslogCtx flattens nested Ctx values into dotted parameter names.
+func flattenSyslogCtx(params map[string]string, prefix string, context Ctx) {
+	for key, value := range context {
+		if nestedCtx, ok := value.(Ctx); ok {
+			flattenSyslogCtx(params, prefix+key+".", nestedCtx)
+			continue
+		}
+		params[prefix+key] = fmt.Sprintf("%+v", value)
+	}
+}
+
+func writeSyslogElement(builder *strings.Builder, id string, params map[string]string) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	builder.WriteString("[" + id)
+	for _, name := range names {
+		builder.WriteString(" " + syslogParamName(name) + `="`)
+		value := params[name]
+		for i := 0; i < len(value); i++ {
+			if c := value[i]; c == '"' || c == '\\' || c == ']' {
+				builder.WriteByte('\\')
+			}
+			builder.WriteByte(value[i])
+		}
+		builder.WriteString(`"`)
+	}
+	builder.WriteString("]")
+}
@@ -0,0 +1,296 @@
+package blackbox_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RobertWHurst/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+// readOctetCountedFrames reads RFC 6587 octet counted frames from each
+// connection accepted by listener and sends them to frames.
+func readOctetCountedFrames(listener net.Listener, frames chan<- string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				lengthStr, err := reader.ReadString(' ')
+				if err != nil {
+					return
+				}
+				length, err := strconv.Atoi(strings.TrimSuffix(lengthStr, " "))
+				if err != nil {
+					return
+				}
+				frame := make([]byte, length)
+				if _, err := io.ReadFull(reader, frame); err != nil {
+					return
+				}
+				frames <- string(frame)
+			}
+		}(conn)
+	}
+}
+
+func receiveFrame(t *testing.T, frames <-chan string) string {
+	select {
+	case frame := <-frames:
+		return frame
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for syslog message")
+		return ""
+	}
+}
+
+func TestSyslogTargetTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	frames := make(chan string, 10)
+	go readOctetCountedFrames(listener, frames)
+
+	syslogTarget := blackbox.NewSyslogTarget("tcp", listener.Addr().String()).
+		SetFacility(blackbox.SyslogLocal0).
+		SetHostname("host-1").
+		SetAppName("my app")
+	defer syslogTarget.Close(context.Background())
+
+	err = syslogTarget.TryLog("AAA-AAA", blackbox.Warn, []any{"Hello", "World"}, blackbox.Ctx{
+		"user":    "bob",
+		"quote":   `say "hi" [ok] \o/`,
+		"bad key": 1,
+		"request": blackbox.Ctx{"id": 42},
+	}, nil)
+	assert.NoError(t, err)
+
+	frame := receiveFrame(t, frames)
+	assert.Regexp(t, `^<132>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(Z|[+-]\d\d:\d\d) host-1 my_app \d+ - `, frame)
+	assert.True(t, strings.HasSuffix(frame, ` - [ctx@32473 bad_key="1" quote="say \"hi\" [ok\] \\o/" request.id="42" user="bob"] Hello World`))
+}
+
+func TestSyslogTargetSeverities(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	frames := make(chan string, 10)
+	go readOctetCountedFrames(listener, frames)
+
+	syslogTarget := blackbox.NewSyslogTarget("tcp", listener.Addr().String()).SetLevel(blackbox.Debug)
+	defer syslogTarget.Close(context.Background())
+
+	expected := map[blackbox.Level]string{
+		blackbox.Debug:   "<15>",
+		blackbox.Verbose: "<14>",
+		blackbox.Info:    "<14>",
+		blackbox.Warn:    "<12>",
+		blackbox.Error:   "<11>",
+		blackbox.Fatal:   "<10>",
+		blackbox.Panic:   "<9>",
+	}
+	assert.NoError(t, syslogTarget.TryLog("AAA-AAA", blackbox.Trace, []any{"Dropped"}, nil, nil))
+	for level := blackbox.Debug; level <= blackbox.Panic; level++ {
+		assert.NoError(t, syslogTarget.TryLog("AAA-AAA", level, []any{"Message"}, nil, nil))
+		frame := receiveFrame(t, frames)
+		assert.True(t, strings.HasPrefix(frame, expected[level]+"1 "), frame)
+		assert.True(t, strings.HasSuffix(frame, " - Message"), frame)
+	}
+}
+
+func TestSyslogTargetUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	syslogTarget := blackbox.NewSyslogTarget("udp", conn.LocalAddr().String()).
+		ShowLoggerID(true).
+		UseSource(true)
+	defer syslogTarget.Close(context.Background())
+
+	err = syslogTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Message"}, nil, func() *blackbox.Source {
+		return &blackbox.Source{File: "main.go", Line: 12, Function: "main.main"}
+	})
+	assert.NoError(t, err)
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	assert.NoError(t, err)
+
+	message := string(buf[:n])
+	assert.True(t, strings.HasPrefix(message, "<14>1 "), message)
+	assert.True(t, strings.HasSuffix(message, ` AAA-AAA [source@32473 file="main.go" function="main.main" line="12"] Message`), message)
+}
+
+func TestSyslogTargetConcurrentChanges(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	frames := make(chan string, 500)
+	go readOctetCountedFrames(listener, frames)
+
+	syslogTarget := blackbox.NewSyslogTarget("tcp", listener.Addr().String())
+	defer syslogTarget.Close(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				syslogTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Message"}, blackbox.Ctx{"count": j}, nil)
+			}
+		}()
+	}
+
+	for j := 0; j < 100; j++ {
+		syslogTarget.SetFacility(blackbox.SyslogLocal0 + blackbox.SyslogFacility(j%8))
+		syslogTarget.SetHostname("host" + strconv.Itoa(j))
+		syslogTarget.SetAppName("app" + strconv.Itoa(j))
+		syslogTarget.SetStructuredDataID("ctx" + strconv.Itoa(j) + "@32473")
+	}
+	wg.Wait()
+
+	syslogTarget.SetFacility(blackbox.SyslogLocal7).SetHostname("host").SetAppName("app")
+	assert.NoError(t, syslogTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Last"}, nil, nil))
+
+	for {
+		frame := receiveFrame(t, frames)
+		if strings.HasSuffix(frame, " Last") {
+			assert.True(t, strings.HasPrefix(frame, "<190>1 "), frame)
+			assert.Contains(t, frame, " host app ")
+			return
+		}
+	}
+}
+
+func TestSyslogTargetUnixgram(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	syslogTarget := blackbox.NewSyslogTarget("unixgram", path)
+	defer syslogTarget.Close(context.Background())
+
+	assert.NoError(t, syslogTarget.TryLog("AAA-AAA", blackbox.Error, []any{"Message"}, nil, nil))
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(buf[:n]), "<11>1 "))
+}
+
+func TestSyslogTargetTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(certDER)
+	assert.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{certDER}, PrivateKey: key}},
+	})
+	assert.NoError(t, err)
+	defer listener.Close()
+	frames := make(chan string, 10)
+	go readOctetCountedFrames(listener, frames)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	syslogTarget := blackbox.NewSyslogTarget("tls", listener.Addr().String()).
+		SetTLSConfig(&tls.Config{RootCAs: roots})
+	defer syslogTarget.Close(context.Background())
+
+	assert.NoError(t, syslogTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Secure"}, nil, nil))
+	assert.True(t, strings.HasSuffix(receiveFrame(t, frames), " - Secure"))
+}
+
+func TestSyslogTargetReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	frames := make(chan string, 10)
+	go readOctetCountedFrames(listener, frames)
+
+	syslogTarget := blackbox.NewSyslogTarget("tcp", address).
+		SetBackoff(10*time.Millisecond, 20*time.Millisecond)
+	defer syslogTarget.Close(context.Background())
+
+	assert.NoError(t, syslogTarget.TryLog("AAA-AAA", blackbox.Info, []any{"First"}, nil, nil))
+	assert.True(t, strings.HasSuffix(receiveFrame(t, frames), " - First"))
+
+	listener.Close()
+	listener = nil
+	for i := 0; i < 20 && listener == nil; i++ {
+		listener, _ = net.Listen("tcp", address)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if listener == nil {
+		t.Skip("could not listen on the same address again")
+	}
+	defer listener.Close()
+	go readOctetCountedFrames(listener, frames)
+
+	assert.Eventually(t, func() bool {
+		syslogTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Second"}, nil, nil)
+		select {
+		case frame := <-frames:
+			return strings.HasSuffix(frame, " - Second")
+		case <-time.After(20 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSyslogTargetBackoff(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	syslogTarget := blackbox.NewSyslogTarget("tcp", address).
+		SetBackoff(time.Hour, time.Hour)
+
+	err = syslogTarget.TryLog("AAA-AAA", blackbox.Info, []any{"First"}, nil, nil)
+	assert.Error(t, err)
+
+	err = syslogTarget.TryLog("AAA-AAA", blackbox.Info, []any{"Second"}, nil, nil)
+	assert.ErrorContains(t, err, "retrying in")
+}
@@ -1,9 +1,16 @@
 package blackbox
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Source struct {
@@ -18,60 +25,530 @@ type Target interface {
 	Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source)
 }
 
+// ErrorTarget is an optional extension of Target for targets that can fail,
+// for example when writing to a closed pipe. When a target implements
+// ErrorTarget, the logger calls TryLog in place of Log, and passes any error
+// returned to the handler set with Logger.OnTargetError.
+type ErrorTarget interface {
+	Target
+	TryLog(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error
+}
+
+// TimedTarget is an optional extension of Target for targets that record the
+// time of each entry. Usually targets use the time an entry reaches them, but
+// when the time an entry was created is known, such as for records passed to
+// SlogHandler, the logger calls LogAt in place of Log, TryLog or LogFields,
+// with any fields merged into the context, and handles any error returned in
+// the same way.
+type TimedTarget interface {
+	Target
+	LogAt(at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error
+}
+
+// Flusher is an optional interface for targets that buffer entries. Flush
+// should block until buffered entries have been written, or until ctx is done.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Closer is an optional interface for targets that hold resources such as
+// files, connections or goroutines. Close should flush any buffered entries
+// and then release those resources, giving up when ctx is done.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// Enabler is an optional interface for targets that only accept entries at
+// some levels. When no target of a logger is enabled for a level, entries at
+// that level are dropped before any work is done to build them.
+type Enabler interface {
+	Enabled(level Level) bool
+}
+
+// SourceUser is an optional interface for targets that can report whether
+// they make use of the source of an entry. When no target of a logger uses
+// the source, the logger skips capturing the call stack. Targets that do not
+// implement SourceUser are assumed to use the source.
+type SourceUser interface {
+	UsesSource() bool
+}
+
+// TargetHandle refers to a target added to a logger with AddTarget, and can be
+// used to remove it again.
+type TargetHandle struct {
+	targetSet *targetSet
+	target    Target
+}
+
+// Target returns the target the handle refers to.
+func (h *TargetHandle) Target() Target {
+	return h.target
+}
+
+// Detach removes the target from the logger it was added to. Entries logged
+// afterwards are no longer passed to it. The target is not flushed or closed.
+// Calling Detach more than once has no effect.
+func (h *TargetHandle) Detach() {
+	h.targetSet.update(func(state *targetState) {
+		for index, handle := range state.handles {
+			if handle == h {
+				state.removeTarget(index)
+				return
+			}
+		}
+	})
+}
+
+// targetSet holds the targets, processors and error handler shared by a
+// logger and its sub loggers. They are kept in an immutable targetState that
+// is replaced as a whole when anything changes, so entries can be logged
+// without taking a lock.
+//
+// A target set created by WithTargets has a parent. Entries logged through it
+// are processed by the processors of its parents followed by its own, then
+// passed to the targets of its parents followed by its own.
 type targetSet struct {
-	targets     []Target
+	parent      *targetSet
+	state       atomic.Pointer[targetState]
 	targetsLock sync.Mutex
 }
 
-func (t *targetSet) log(loggerID string, level Level, values []any, context Ctx, pc []uintptr) {
-	for index, value := range values {
-		if ctx, ok := value.(Ctx); ok {
-			context = context.Extend(ctx)
-			values = append(values[:index], values[index+1:]...)
+// targetState holds the targets of a target set along with the handle each
+// was added with, at the same index.
+type targetState struct {
+	targets    []Target
+	handles    []*TargetHandle
+	processors []Processor
+	onError    func(Target, error)
+}
+
+// emptyTargetState is the state of a target set that has not been changed.
+var emptyTargetState = &targetState{}
+
+// load returns the current state of the target set.
+func (t *targetSet) load() *targetState {
+	if state := t.state.Load(); state != nil {
+		return state
+	}
+	return emptyTargetState
+}
+
+// update replaces the state of the target set with a copy changed by change.
+// Slices in the copy are shared with the previous state, so change must not
+// modify them in place.
+func (t *targetSet) update(change func(state *targetState)) {
+	t.targetsLock.Lock()
+	state := *t.load()
+	change(&state)
+	t.state.Store(&state)
+	t.targetsLock.Unlock()
+}
+
+// lineage appends the state of each parent of the target set, starting from
+// the root, followed by its own state, to states.
+func (t *targetSet) lineage(states []*targetState) []*targetState {
+	if t.parent != nil {
+		states = t.parent.lineage(states)
+	}
+	return append(states, t.load())
+}
+
+// removeTarget removes the target at index without modifying the slices
+// shared with the previous state.
+func (s *targetState) removeTarget(index int) {
+	s.targets = append(s.targets[:index:index], s.targets[index+1:]...)
+	s.handles = append(s.handles[:index:index], s.handles[index+1:]...)
+}
+
+// log passes an entry to the targets of the set and its parents. If at is not
+// zero, it is the time the entry was created, and is passed to targets that
+// implement TimedTarget.
+func (t *targetSet) log(at time.Time, loggerID string, level Level, values []any, context Ctx, fields []Field, pc []uintptr) {
+	values, context, fields = splitValues(values, context, fields)
+
+	getSource := noSource
+	if len(pc) != 0 {
+		getSource = sourceFromPC(pc)
+	}
+
+	var stateBuffer [4]*targetState
+	states := t.lineage(stateBuffer[:0])
+
+	var processors []Processor
+	var onError func(Target, error)
+	for _, state := range states {
+		if len(processors) == 0 {
+			processors = state.processors
+		} else if len(state.processors) != 0 {
+			processors = append(processors[:len(processors):len(processors)], state.processors...)
+		}
+		if state.onError != nil {
+			onError = state.onError
 		}
 	}
 
-	getSource := func() *Source {
-		if len(pc) == 0 {
-			return nil
+	if len(processors) != 0 {
+		if len(fields) != 0 {
+			context = context.Extend(fieldsCtx(fields))
+			fields = nil
 		}
+		entry, ok := processEntry(processors, loggerID, level, values, context, getSource)
+		if !ok {
+			return
+		}
+		loggerID, level, values, context, getSource = entry.LoggerID, entry.Level, entry.Values, entry.Context, entry.Source
+	}
 
-		frames := runtime.CallersFrames(pc)
-		for {
-			frame, more := frames.Next()
-			if !more {
-				break
+	// Targets that do not implement FieldTarget share a single copy of the
+	// context with the fields merged into it.
+	var fieldContext Ctx
+	mergedContext := func() Ctx {
+		if len(fields) == 0 {
+			return context
+		}
+		if fieldContext == nil {
+			fieldContext = context.Extend(fieldsCtx(fields))
+		}
+		return fieldContext
+	}
+	for _, state := range states {
+		for _, target := range state.targets {
+			// AsyncTarget logs from its own goroutine, so the error handler
+			// is queued with the entry.
+			if asyncTarget, ok := target.(*AsyncTarget); ok {
+				asyncTarget.enqueue(asyncEntry{
+					at:        at,
+					loggerID:  loggerID,
+					level:     level,
+					values:    values,
+					context:   mergedContext(),
+					getSource: getSource,
+					onError:   onError,
+				})
+				continue
 			}
-			funcPathChunks := strings.Split(frame.Function, "/")
-			if len(funcPathChunks) == 0 {
+			var err error
+			if timedTarget, ok := target.(TimedTarget); ok && !at.IsZero() {
+				err = logAtToTarget(timedTarget, at, loggerID, level, values, mergedContext(), getSource)
+			} else if fieldTarget, ok := target.(FieldTarget); ok && len(fields) != 0 {
+				err = logFieldsToTarget(fieldTarget, loggerID, level, values, context, fields, getSource)
+			} else {
+				err = logToTarget(target, loggerID, level, values, mergedContext(), getSource)
+			}
+			if err != nil {
+				handleTargetError(onError, target, err)
+			}
+		}
+	}
+}
+
+// splitValues moves any Ctx values and key value pairs into context, and any
+// Field values into fields, returning the values that remain for the message.
+// Neither the given values nor context are modified.
+//
+// Key value pairs are read only after the first value of the message. From
+// there, a string followed by another value is taken as a key and its value.
+// Any other value, including a string left without a value at the end, stays
+// in the message as before.
+func splitValues(values []any, context Ctx, fields []Field) ([]any, Ctx, []Field) {
+	var remaining []any
+	ownsContext := false
+	hasMessage := false
+	for index := 0; index < len(values); index++ {
+		switch typedValue := values[index].(type) {
+		case Ctx:
+			remaining = splitRemaining(remaining, values, index)
+			context = context.Extend(typedValue)
+			ownsContext = true
+		case Field:
+			remaining = splitRemaining(remaining, values, index)
+			fields = append(fields[:len(fields):len(fields)], typedValue)
+		default:
+			key, isKey := typedValue.(string)
+			if !isKey || !hasMessage || !isPairValue(values, index+1) {
+				hasMessage = true
+				if remaining != nil {
+					remaining = append(remaining, typedValue)
+				}
 				continue
 			}
-			packageAndFuncName := strings.Split(funcPathChunks[len(funcPathChunks)-1], ".")
-			if len(packageAndFuncName) == 0 {
+			remaining = splitRemaining(remaining, values, index)
+			if !ownsContext {
+				context = context.Extend(nil)
+				ownsContext = true
+			}
+			if value := values[index+1]; value != nil {
+				context[key] = value
+			}
+			index++
+		}
+	}
+	if remaining == nil {
+		return values, context, fields
+	}
+	return remaining, context, fields
+}
+
+// splitRemaining returns remaining, or if it has not been started, a new slice
+// holding the message values that come before index.
+func splitRemaining(remaining []any, values []any, index int) []any {
+	if remaining != nil {
+		return remaining
+	}
+	return append(make([]any, 0, len(values)-1), values[:index]...)
+}
+
+// isPairValue reports whether values holds a value at index that can follow a
+// key. Ctx and Field values are never taken as the value of a pair.
+func isPairValue(values []any, index int) bool {
+	if index >= len(values) {
+		return false
+	}
+	switch values[index].(type) {
+	case Ctx, Field:
+		return false
+	}
+	return true
+}
+
+// wants reports whether any target is enabled for entries at level, and
+// whether any of those targets use the source of the entry. Processors may
+// change the level of an entry or read its source, so when there are any,
+// every entry is wanted along with its source.
+func (t *targetSet) wants(level Level) (wanted bool, wantsSource bool) {
+	var stateBuffer [4]*targetState
+	states := t.lineage(stateBuffer[:0])
+
+	for _, state := range states {
+		if len(state.processors) != 0 {
+			return true, true
+		}
+	}
+	for _, state := range states {
+		for _, target := range state.targets {
+			if enabler, ok := target.(Enabler); ok && !enabler.Enabled(level) {
 				continue
 			}
+			wanted = true
+			if sourceUser, ok := target.(SourceUser); !ok || sourceUser.UsesSource() {
+				return true, true
+			}
+		}
+	}
+	return wanted, false
+}
+
+// noSource is passed to targets in place of a getSource function when the
+// source of an entry was not captured.
+func noSource() *Source {
+	return nil
+}
+
+// sourceFromPC returns a getSource function that finds the first frame of pc
+// outside of blackbox.
+func sourceFromPC(pc []uintptr) func() *Source {
+	return func() *Source {
+		frames := runtime.CallersFrames(pc)
+		for {
+			frame, more := frames.Next()
+			funcPathChunks := strings.Split(frame.Function, "/")
+			packageAndFuncName := strings.Split(funcPathChunks[len(funcPathChunks)-1], ".")
 			packageName := packageAndFuncName[0]
-			if packageName != "blackbox" {
+			if frame.Function != "" && packageName != "blackbox" {
 				return &Source{
 					Function: frame.Function,
 					File:     frame.File,
 					Line:     frame.Line,
 				}
 			}
+			if !more {
+				break
+			}
 		}
 
 		return nil
 	}
+}
 
-	t.targetsLock.Lock()
-	for _, target := range t.targets {
-		target.Log(loggerID, level, values, context, getSource)
+func (t *targetSet) addProcessor(processor Processor) {
+	t.update(func(state *targetState) {
+		state.processors = append(state.processors[:len(state.processors):len(state.processors)], processor)
+	})
+}
+
+func (t *targetSet) setErrorHandler(handler func(Target, error)) {
+	t.update(func(state *targetState) {
+		state.onError = handler
+	})
+}
+
+// handleTargetError passes a target error to onError, or to
+// reportTargetError if there is no handler.
+func handleTargetError(onError func(Target, error), target Target, err error) {
+	if onError == nil {
+		reportTargetError(target, err)
+		return
 	}
-	t.targetsLock.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			reportTargetError(target, fmt.Errorf("blackbox: target error handler panicked: %v", r))
+		}
+	}()
+	onError(target, err)
 }
 
-func (t *targetSet) addTarget(target Target) {
-	t.targetsLock.Lock()
-	t.targets = append(t.targets, target)
-	t.targetsLock.Unlock()
+// logToTarget passes an entry to the target, using TryLog if the target
+// implements ErrorTarget. Panics within the target are recovered and returned
+// as errors so that a misbehaving target can not crash the process.
+func logToTarget(target Target, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("blackbox: target panicked: %v", r)
+		}
+	}()
+	if errorTarget, ok := target.(ErrorTarget); ok {
+		return errorTarget.TryLog(loggerID, level, values, context, getSource)
+	}
+	target.Log(loggerID, level, values, context, getSource)
+	return nil
+}
+
+// logFieldsToTarget passes an entry with fields to a FieldTarget, recovering
+// from panics in the same way as logToTarget.
+func logFieldsToTarget(target FieldTarget, loggerID string, level Level, values []any, context Ctx, fields []Field, getSource func() *Source) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("blackbox: target panicked: %v", r)
+		}
+	}()
+	return target.LogFields(loggerID, level, values, context, fields, getSource)
+}
+
+// logAtToTarget passes an entry with a known time to a TimedTarget,
+// recovering from panics in the same way as logToTarget.
+func logAtToTarget(target TimedTarget, at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("blackbox: target panicked: %v", r)
+		}
+	}()
+	return target.LogAt(at, loggerID, level, values, context, getSource)
+}
+
+// logTimedToTarget passes an entry to the target with logAtToTarget if at is
+// not zero and the target implements TimedTarget, and with logToTarget
+// otherwise. It is used by targets that wrap other targets.
+func logTimedToTarget(target Target, at time.Time, loggerID string, level Level, values []any, context Ctx, getSource func() *Source) error {
+	if timedTarget, ok := target.(TimedTarget); ok && !at.IsZero() {
+		return logAtToTarget(timedTarget, at, loggerID, level, values, context, getSource)
+	}
+	return logToTarget(target, loggerID, level, values, context, getSource)
+}
+
+// reportTargetError is the fallback used when a target error has nowhere else
+// to go. It writes the error to stderr.
+func reportTargetError(target Target, err error) {
+	fmt.Fprintf(os.Stderr, "blackbox: %T failed to log entry: %v\n", target, err)
+}
+
+func (t *targetSet) addTarget(target Target) *TargetHandle {
+	handle := &TargetHandle{targetSet: t, target: target}
+	t.update(func(state *targetState) {
+		state.targets = append(state.targets[:len(state.targets):len(state.targets)], target)
+		state.handles = append(state.handles[:len(state.handles):len(state.handles)], handle)
+	})
+	return handle
+}
+
+// removeTarget removes every occurrence of target, reporting whether any were
+// found.
+func (t *targetSet) removeTarget(target Target) bool {
+	removed := false
+	t.update(func(state *targetState) {
+		for index := len(state.targets) - 1; index >= 0; index-- {
+			if sameTarget(state.targets[index], target) {
+				state.removeTarget(index)
+				removed = true
+			}
+		}
+	})
+	return removed
+}
+
+// setTargets replaces the targets of the set. Handles for the previous targets
+// no longer have any effect.
+func (t *targetSet) setTargets(targets []Target) {
+	handles := make([]*TargetHandle, len(targets))
+	for index, target := range targets {
+		handles[index] = &TargetHandle{targetSet: t, target: target}
+	}
+	targets = append([]Target(nil), targets...)
+	t.update(func(state *targetState) {
+		state.targets = targets
+		state.handles = handles
+	})
+}
+
+// sameTarget reports whether a and b are the same target. Targets of types
+// that can not be compared are never the same, rather than causing a panic.
+func sameTarget(a Target, b Target) bool {
+	targetType := reflect.TypeOf(a)
+	if targetType == nil || targetType != reflect.TypeOf(b) || !targetType.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// flush flushes the targets of the set and its parents, as these are all the
+// targets entries logged through the set are written to.
+func (t *targetSet) flush(ctx context.Context) error {
+	var errs []error
+	for _, state := range t.lineage(nil) {
+		errs = append(errs, flushTargets(ctx, state.targets))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func flushTargets(ctx context.Context, targets []Target) error {
+	var errs []error
+	for _, target := range targets {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if flusher, ok := target.(Flusher); ok {
+			if err := flusher.Flush(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// close closes the targets of the set. The targets of its parents are still
+// in use by the parent loggers, so they are only flushed.
+func (t *targetSet) close(ctx context.Context) error {
+	var errs []error
+	for _, target := range t.load().targets {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if closer, ok := target.(Closer); ok {
+			if err := closer.Close(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		} else if flusher, ok := target.(Flusher); ok {
+			if err := flusher.Flush(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if t.parent != nil && ctx.Err() == nil {
+		errs = append(errs, t.parent.flush(ctx))
+	}
+	return errors.Join(errs...)
 }
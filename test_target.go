@@ -1,7 +1,10 @@
 package blackbox
 
+import "sync"
+
 type TestTarget struct {
 	logged []Logged
+	lock   sync.Mutex
 }
 
 var _ Target = &TestTarget{}
@@ -21,29 +24,43 @@ type Logged struct {
 }
 
 func (t *TestTarget) Log(loggerID string, level Level, values []any, context Ctx, getSource func() *Source) {
+	var source *Source
+	if getSource != nil {
+		source = getSource()
+	}
+	t.lock.Lock()
 	t.logged = append(t.logged, Logged{
 		LoggerID: loggerID,
 		Level:    level,
 		Values:   values,
 		Context:  context,
-		Source:   getSource(),
+		Source:   source,
 	})
+	t.lock.Unlock()
 }
 
 func (t *TestTarget) Reset() {
+	t.lock.Lock()
 	t.logged = nil
+	t.lock.Unlock()
 }
 
 func (t *TestTarget) LastLogged() (Logged, bool) {
-	if len(t.logged) == 0 {
-		return Logged{}, false
-	}
-	return t.logged[0], true
+	return t.PreviouslyLogged(0)
 }
 
 func (t *TestTarget) PreviouslyLogged(i int) (Logged, bool) {
-	if len(t.logged) >= i-1 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	index := len(t.logged) - 1 - i
+	if i < 0 || index < 0 {
 		return Logged{}, false
 	}
-	return t.logged[0], true
+	return t.logged[index], true
+}
+
+func (t *TestTarget) AllLogged() []Logged {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append(make([]Logged, 0, len(t.logged)), t.logged...)
 }